@@ -0,0 +1,33 @@
+#!/usr/bin/env python3
+# Example of driving the engine from Python through the c-shared build:
+#   $ make bsquare.so
+#   $ python3 misc/bsquare.py
+import ctypes
+import os.path
+
+lib = ctypes.CDLL(os.path.join(os.path.dirname(__file__), "..", "bsquare.so"))
+lib.bsquare_evaluate.argtypes = [ctypes.c_ulonglong]
+lib.bsquare_evaluate.restype = ctypes.c_int
+lib.bsquare_best_move.argtypes = [ctypes.c_ulonglong]
+lib.bsquare_best_move.restype = ctypes.c_int
+lib.bsquare_legal_moves.argtypes = [ctypes.c_ulonglong]
+lib.bsquare_legal_moves.restype = ctypes.c_uint
+lib.bsquare_place.argtypes = [ctypes.c_ulonglong, ctypes.c_int]
+lib.bsquare_place.restype = ctypes.c_ulonglong
+lib.bsquare_pass.argtypes = [ctypes.c_ulonglong]
+lib.bsquare_pass.restype = ctypes.c_ulonglong
+
+# Perfect play against itself, printing each move and the evaluation
+state = 0
+while True:
+    move = lib.bsquare_best_move(state)
+    if move == -3:
+        raise RuntimeError("invalid state or no solved table")
+    if move == -2:
+        break
+    if move == -1:
+        state = lib.bsquare_pass(state)
+        print("pass")
+    else:
+        state = lib.bsquare_place(state, move)
+        print(move + 1, lib.bsquare_evaluate(state))
@@ -0,0 +1,314 @@
+package bsquare
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/bits"
+	"os"
+	"time"
+)
+
+type bounds struct {
+	lo, hi int8
+}
+
+// AlphaBeta is an alpha-beta game evaluator with a transposition table
+// of score bounds. Searches use an aspiration window centered on the
+// previous result, re-searching with a widened window when the score
+// falls outside. Scores change little between consecutive positions, so
+// this pays off when evaluating a game move by move.
+//
+// With Ordering, moves are tried killers first, then by the history
+// heuristic: moves that recently caused cutoffs at the same turn, then
+// moves that caused cutoffs anywhere, weighted by remaining depth.
+type AlphaBeta struct {
+	Window     int  // aspiration half-width, or 0 for a full window
+	Ordering   bool // enable killer and history move ordering
+	Nodes      int  // nodes visited
+	Hits       int  // nodes resolved by the table
+	Cutoffs    int  // beta cutoffs
+	Searches   int  // calls to Evaluate
+	Researches int  // searches repeated after falling outside the window
+
+	// Log optionally traces searches: a summary per search at info
+	// level, and nodes per turn and time per root move at debug level.
+	Log *slog.Logger
+
+	guess   int
+	table   *boundTable
+	history [2][25]int
+	killers [64][2]int8
+	root    int
+	turns   [64]int
+	deepest int
+}
+
+// NewAlphaBeta returns an alpha-beta evaluator with an empty, unbounded
+// table.
+func NewAlphaBeta(window int) *AlphaBeta {
+	return NewAlphaBetaSize(window, 0)
+}
+
+// NewAlphaBetaSize returns an alpha-beta evaluator whose table uses at
+// most size bytes, or is unbounded if size is zero. A smaller table
+// trades search time for memory.
+func NewAlphaBetaSize(window, size int) *AlphaBeta {
+	e := &AlphaBeta{Window: window, table: newBoundTable(size)}
+	for i := range e.killers {
+		e.killers[i] = [2]int8{-1, -1}
+	}
+	return e
+}
+
+// moves appends the legal moves to buf in search order.
+func (e *AlphaBeta) moves(s State, m Mask, buf []int) []int {
+	for b := m.LegalBits(m.Turn()); b != 0; b &= b - 1 {
+		buf = append(buf, bits.TrailingZeros32(b))
+	}
+	if !e.Ordering {
+		return buf
+	}
+
+	var keys [25]int
+	who := s.ToMove()
+	killers := e.killers[s.Turn()]
+	for j, i := range buf {
+		switch int8(i) {
+		case killers[0]:
+			keys[j] = 1 << 30
+		case killers[1]:
+			keys[j] = 1 << 29
+		default:
+			keys[j] = e.history[who][i]
+		}
+	}
+	for j := 1; j < len(buf); j++ { // insertion sort, descending
+		for k := j; k > 0 && keys[k] > keys[k-1]; k-- {
+			keys[k], keys[k-1] = keys[k-1], keys[k]
+			buf[k], buf[k-1] = buf[k-1], buf[k]
+		}
+	}
+	return buf
+}
+
+// cutoff records a move that caused a cutoff.
+func (e *AlphaBeta) cutoff(s State, i int) {
+	e.Cutoffs++
+	if e.Ordering {
+		remaining := 50 - s.Turn()
+		e.history[s.ToMove()][i] += remaining * remaining
+		killers := &e.killers[s.Turn()]
+		if killers[0] != int8(i) {
+			killers[1] = killers[0]
+			killers[0] = int8(i)
+		}
+	}
+}
+
+// Evaluate the minimax score at a game state.
+func (e *AlphaBeta) Evaluate(s State, m Mask) int {
+	if e.Log == nil {
+		return e.evaluate(s, m)
+	}
+
+	start := time.Now()
+	nodes, hits, cutoffs := e.Nodes, e.Hits, e.Cutoffs
+	e.turns = [64]int{}
+	e.root = s.Turn()
+	v := e.evaluate(s, m)
+	ctx := context.Background()
+	if e.Log.Enabled(ctx, slog.LevelDebug) {
+		for turn, n := range e.turns {
+			if n > 0 {
+				e.Log.Debug("turn", "turn", turn, "nodes", n)
+			}
+		}
+	}
+	n := e.Nodes - nodes
+	e.Log.Info("search",
+		"position", s.String(),
+		"score", v,
+		"nodes", n,
+		"cutoffs", e.Cutoffs-cutoffs,
+		"hit_rate", float64(e.Hits-hits)/float64(max(n, 1)),
+		"table", e.table.len(),
+		"duration", time.Since(start))
+	return v
+}
+
+func (e *AlphaBeta) evaluate(s State, m Mask) int {
+	e.Searches++
+	if e.Window <= 0 {
+		e.guess = e.search(s, m, -26, +26)
+		return e.guess
+	}
+	alpha := e.guess - e.Window
+	beta := e.guess + e.Window
+	v := e.search(s, m, alpha, beta)
+	if v <= alpha {
+		e.Researches++
+		v = e.search(s, m, -26, v+1)
+	} else if v >= beta {
+		e.Researches++
+		v = e.search(s, m, v-1, +26)
+	}
+	e.guess = v
+	return v
+}
+
+// child searches the position after a move, tracing root moves.
+func (e *AlphaBeta) child(s State, m Mask, i, alpha, beta int) int {
+	if e.Log == nil || s.Turn() != e.root {
+		return e.search(s.Place(i), m.Place(i), alpha, beta)
+	}
+	start := time.Now()
+	nodes := e.Nodes
+	v := e.search(s.Place(i), m.Place(i), alpha, beta)
+	e.Log.Debug("root move",
+		"square", i+1,
+		"score", v,
+		"alpha", alpha,
+		"beta", beta,
+		"nodes", e.Nodes-nodes,
+		"duration", time.Since(start))
+	return v
+}
+
+// search returns the exact score when it lies within (alpha, beta), and
+// otherwise a bound on the far side of the window (fail-soft).
+func (e *AlphaBeta) search(s State, m Mask, alpha, beta int) int {
+	e.Nodes++
+	e.turns[s.Turn()]++
+	e.deepest = max(e.deepest, s.Turn())
+	s0 := s.Canonicalize()
+	b, ok := e.table.get(s0)
+	if !ok {
+		b = bounds{-25, +25}
+	}
+	if int(b.lo) >= beta {
+		e.Hits++
+		return int(b.lo)
+	}
+	if int(b.hi) <= alpha {
+		e.Hits++
+		return int(b.hi)
+	}
+	alpha = max(alpha, int(b.lo))
+	beta = min(beta, int(b.hi))
+
+	var v int
+	if s.IsComplete(m) {
+		v = s.Score()
+		e.table.put(s0, bounds{int8(v), int8(v)})
+		return v
+	} else if s.NoMoves(m) {
+		v = e.search(s.Pass(), m.Pass(), alpha, beta)
+	} else if s.ToMove() == FirstPlayer {
+		v = -26
+		a := alpha
+		var buf [25]int
+		for _, i := range e.moves(s, m, buf[:0]) {
+			v = max(v, e.child(s, m, i, a, beta))
+			a = max(a, v)
+			if v >= beta {
+				e.cutoff(s, i)
+				break
+			}
+		}
+	} else {
+		v = +26
+		bt := beta
+		var buf [25]int
+		for _, i := range e.moves(s, m, buf[:0]) {
+			v = min(v, e.child(s, m, i, alpha, bt))
+			bt = min(bt, v)
+			if v <= alpha {
+				e.cutoff(s, i)
+				break
+			}
+		}
+	}
+
+	if v <= alpha {
+		b.hi = int8(v)
+	} else if v >= beta {
+		b.lo = int8(v)
+	} else {
+		b = bounds{int8(v), int8(v)}
+	}
+	e.table.put(s0, b)
+	return v
+}
+
+// searchMain implements the "search" command. Every position along the
+// game is evaluated in turn, as during interactive analysis.
+func searchMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	window := flags.Int("window", 1, "aspiration window half-width (0: full)")
+	hashSize := flags.Int("hash-size", 0, "transposition table size in MiB (0: unbounded)")
+	order := flags.Bool("order", true, "killer and history move ordering")
+	compare := flags.Bool("compare", false, "compare node counts against naive ordering")
+	logLevel := flags.String("log-level", "", "trace searches to stderr (debug, info)")
+	in := addInput(flags)
+	format := addFormat(flags, "text")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	states := []State{}
+	if *in.pos != "" {
+		s, _, err := in.position(flags.Args())
+		if err != nil {
+			return err
+		}
+		states = append(states, s)
+	} else {
+		g, err := in.game(flags.Args())
+		if err != nil {
+			return err
+		}
+		states = g.History()
+	}
+
+	r := new(Report)
+	if *compare {
+		tab := r.Table("Orderings", "ordering", "nodes", "cutoffs", "time")
+		for _, ordering := range []bool{false, true} {
+			e := NewAlphaBetaSize(*window, *hashSize<<20)
+			e.Ordering = ordering
+			start := time.Now()
+			for _, s := range states {
+				e.Evaluate(s, s.Derive())
+			}
+			name := "naive"
+			if ordering {
+				name = "history"
+			}
+			tab.Add(name, e.Nodes, e.Cutoffs, time.Since(start))
+		}
+		return format.Write(os.Stdout, r)
+	}
+
+	e := NewAlphaBetaSize(*window, *hashSize<<20)
+	e.Ordering = *order
+	if *logLevel != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+			return err
+		}
+		opts := &slog.HandlerOptions{Level: level}
+		e.Log = slog.New(slog.NewTextHandler(os.Stderr, opts))
+	}
+	var stats SearchStats
+	tab := r.Table("Scores", "turn", "score", "nodes", "time")
+	for _, s := range states {
+		v, st := e.EvaluateStats(s, s.Derive())
+		stats.Add(st)
+		tab.Add(s.Turn(), signed(v), st.Nodes, st.Duration)
+	}
+	stats.AddTo(r)
+	r.Add("Cutoffs", e.Cutoffs)
+	r.AddText("Re-searches", e.Researches, fmt.Sprintf("%d of %d", e.Researches, e.Searches))
+	return format.Write(os.Stdout, r)
+}
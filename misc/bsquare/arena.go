@@ -0,0 +1,232 @@
+package bsquare
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+type arenaRequest struct {
+	ID       json.RawMessage `json:"id,omitempty"`
+	Position string          `json:"position"`
+	Moves    []int           `json:"moves"`
+	TimeMS   int             `json:"time_ms"`
+}
+
+type arenaResponse struct {
+	ID        json.RawMessage `json:"id,omitempty"`
+	Move      *int            `json:"move,omitempty"`
+	Score     *int            `json:"score,omitempty"`
+	ElapsedMS int64           `json:"elapsed_ms"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// Arena plays an engine as a bot over newline-delimited JSON, for
+// generic game platforms and bot arenas. Once ready the bot announces
+// itself:
+//
+//	{"ready": true, "name": "bsquare", "engine": "perfect"}
+//
+// then answers each request with one line:
+//
+//	{"id": 7, "position": "x..../...../...../...../.....:1", "time_ms": 500}
+//	{"id": 7, "move": 13, "elapsed_ms": 0}
+//
+// A request gives the position as a string or ID, or the moves played
+// from the empty board (squares 1-25, passes implicit), and an optional
+// time budget replacing the default. Moves are squares 1-25, or 0 to
+// pass. The id, of any JSON type, is echoed back. Errors are reported
+// in place of a move, and the bot exits at the end of input.
+//
+// Arena is the adapter behind the arena command, and other bot hosts
+// may serve their own engines with it:
+//
+//	a := bsquare.Arena{Engine: myEngine{}}
+//	err := a.Serve(ctx, os.Stdin, os.Stdout, "mine")
+type Arena struct {
+	Engine Engine
+	Table  Minimax       // optional, for scoring moves
+	Budget time.Duration // default time per move, one second if zero
+}
+
+// state returns the position of a request.
+func (a *arenaRequest) state() (State, Mask, error) {
+	if a.Position != "" && a.Moves != nil {
+		return 0, 0, errors.New("both position and moves given")
+	}
+	if a.Position != "" {
+		s, err := ParsePosition(a.Position)
+		return s, s.Derive(), err
+	}
+	g := new(Game)
+	for _, i := range a.Moves {
+		if i < 1 || i > 25 {
+			return 0, 0, fmt.Errorf("%w: %d", ErrInvalidSquare, i)
+		}
+		if err := g.Play(i - 1); err != nil {
+			return 0, 0, err
+		}
+	}
+	s, m := g.Position()
+	return s, m, nil
+}
+
+// Move chooses a move within the budget. The engine runs concurrently,
+// and if it overruns the budget a random legal move is played instead.
+// Engines must therefore be safe to use from several goroutines. A
+// ContextEngine is given a deadline shortly before the budget ends.
+func (a *Arena) Move(ctx context.Context, s State, m Mask, budget time.Duration) int {
+	if s.NoMoves(m) {
+		return -1
+	}
+	ctx, cancel := context.WithTimeout(ctx, budget)
+	defer cancel()
+	done := make(chan int, 1)
+	go func() {
+		if e, ok := a.Engine.(ContextEngine); ok {
+			// Leave a margin for the reply
+			deadline, _ := ctx.Deadline()
+			dctx, cancel := context.WithDeadline(ctx, deadline.Add(-budget/10))
+			defer cancel()
+			done <- e.MoveContext(dctx, s, m)
+			return
+		}
+		done <- a.Engine.Move(s, m)
+	}()
+	select {
+	case i := <-done:
+		return i
+	case <-ctx.Done():
+		return Random{}.Move(s, m)
+	}
+}
+
+// Serve answers requests from r on w until r ends or the context is
+// cancelled.
+func (a *Arena) Serve(ctx context.Context, r io.Reader, w io.Writer, name string) error {
+	enc := json.NewEncoder(w)
+	ready := struct {
+		Ready  bool   `json:"ready"`
+		Name   string `json:"name"`
+		Engine string `json:"engine"`
+	}{true, "bsquare", name}
+	if err := enc.Encode(ready); err != nil {
+		return err
+	}
+	// Read in the background so that cancellation is not held up
+	// waiting for input
+	lines := make(chan []byte)
+	errc := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			lines <- append([]byte(nil), scanner.Bytes()...)
+		}
+		errc <- scanner.Err()
+		close(lines)
+	}()
+	for {
+		var line []byte
+		var ok bool
+		select {
+		case line, ok = <-lines:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if !ok {
+			return <-errc
+		}
+		if len(line) == 0 {
+			continue
+		}
+		start := time.Now()
+		resp := a.answer(ctx, line)
+		resp.ElapsedMS = time.Since(start).Milliseconds()
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (a *Arena) answer(ctx context.Context, line []byte) arenaResponse {
+	var req arenaRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		return arenaResponse{Error: err.Error()}
+	}
+	resp := arenaResponse{ID: req.ID}
+	s, m, err := req.state()
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	if s.IsComplete(m) {
+		resp.Error = ErrGameOver.Error()
+		return resp
+	}
+	budget := a.Budget
+	if budget <= 0 {
+		budget = time.Second
+	}
+	if req.TimeMS > 0 {
+		budget = time.Duration(req.TimeMS) * time.Millisecond
+	}
+	i := a.Move(ctx, s, m, budget)
+	move := i + 1
+	resp.Move = &move
+	if a.Table != nil {
+		s, m = child(s, m, i)
+		score := a.Table.Evaluate(s, m)
+		resp.Score = &score
+	}
+	return resp
+}
+
+// tableless lists the engines that play without a solved table.
+var tableless = map[string]bool{"random": true, "deepening": true, "expectimax": true}
+
+// arenaMain implements the "arena" command.
+func arenaMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("arena", flag.ContinueOnError)
+	name := flags.String("engine", "perfect", "engine ("+engineUsage()+")")
+	opts := addEngineOptions(flags)
+	budget := flags.Duration("budget", time.Second, "time budget per move when a request gives none")
+	scores := flags.Bool("scores", false, "report the score after each move (requires solving)")
+	seed := addSeed(flags)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return errors.New("usage: arena [flags]")
+	}
+	if *budget <= 0 {
+		return fmt.Errorf("invalid budget: %v", *budget)
+	}
+
+	var t Minimax
+	if !tableless[*name] || *scores {
+		var err error
+		if t, err = Solved(ctx); err != nil {
+			return err
+		}
+	}
+	seed.apply()
+	engine, err := NewEngine(*name, t, opts)
+	if err != nil {
+		return err
+	}
+	a := Arena{Engine: engine, Budget: *budget}
+	if *scores {
+		a.Table = t
+	}
+	err = a.Serve(ctx, os.Stdin, os.Stdout, *name)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+package bsquare
+
+import "math/bits"
+
+// Assessment summarizes a game in progress for a live display, such as
+// an evaluation bar, with metrics that change move by move even while
+// the score holds. Per-player arrays are indexed by Player.
+type Assessment struct {
+	Pieces    [2]int `json:"pieces"`    // pieces placed
+	Open      [2]int `json:"open"`      // empty squares the player may take
+	Exclusive [2]int `json:"exclusive"` // open squares the opponent may not take
+	Score     int    `json:"score"`     // minimax score, first player's perspective
+}
+
+// Assessment assesses a game state, with the score from the table.
+func (t Minimax) Assessment(s State, m Mask) Assessment {
+	a := assess(s, m)
+	a.Score = t.Evaluate(s, m)
+	return a
+}
+
+// assess computes an assessment apart from its score.
+func assess(s State, m Mask) Assessment {
+	var a Assessment
+	var open [2]uint32
+	for who := FirstPlayer; who <= SecondPlayer; who++ {
+		a.Pieces[who] = s.Pieces(who)
+		open[who] = ^uint32(m>>who.offset()) & 0x1ffffff
+		if who == FirstPlayer && m.Turn() == 0 {
+			open[who] &^= 1 << 12
+		}
+		a.Open[who] = bits.OnesCount32(open[who])
+	}
+	for who := FirstPlayer; who <= SecondPlayer; who++ {
+		a.Exclusive[who] = bits.OnesCount32(open[who] &^ open[who.Other()])
+	}
+	return a
+}
+
+// Guaranteed returns the least final score a player can force, from
+// that player's perspective.
+func (a Assessment) Guaranteed(who Player) int {
+	return a.Score * who.Sign()
+}
+
+// assessmentTable adds an assessment to a report, one row per player.
+func assessmentTable(r *Report, a Assessment) {
+	tab := r.Table("Assessment", "player", "pieces", "open", "exclusive", "guaranteed")
+	for who := FirstPlayer; who <= SecondPlayer; who++ {
+		tab.Add(int(who)+1, a.Pieces[who], a.Open[who], a.Exclusive[who], signed(a.Guaranteed(who)))
+	}
+}
@@ -0,0 +1,188 @@
+package bsquare
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Position is a game state paired with its mask.
+type Position struct {
+	State State
+	Mask  Mask
+}
+
+// EvaluateBatch evaluates a list of positions, sharing the table across
+// all evaluations.
+func (t Minimax) EvaluateBatch(ps []Position) []int {
+	scores := make([]int, len(ps))
+	for i, p := range ps {
+		scores[i] = t.Evaluate(p.State, p.Mask)
+	}
+	return scores
+}
+
+// EvaluateParallel is EvaluateBatch split across concurrent workers.
+// Workers read the shared table while recording their new results
+// privately, and these results are merged into the table at the end.
+func (t Minimax) EvaluateParallel(ps []Position, workers int) []int {
+	workers = max(1, min(workers, len(ps)))
+	scores := make([]int, len(ps))
+	locals := make([]Minimax, workers)
+	var wg sync.WaitGroup
+	for w := range locals {
+		locals[w] = New()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := w; i < len(ps); i += workers {
+				p := ps[i]
+				scores[i], _ = locals[w].evaluate(context.Background(), Rules{}, t, nil, p.State, p.Mask)
+			}
+		}()
+	}
+	wg.Wait()
+	for _, local := range locals {
+		for s, score := range local {
+			t[s] = score
+		}
+	}
+	return scores
+}
+
+// readPositions reads position strings or IDs from a JSON array of
+// strings, or otherwise from the first column of CSV records, skipping
+// a "position" header such as evaluate writes.
+func readPositions(r io.Reader, isJSON bool) ([]string, []Position, error) {
+	var names []string
+	if isJSON {
+		if err := json.NewDecoder(r).Decode(&names); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		cr.Comment = '#'
+		records, err := cr.ReadAll()
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(records) > 0 && strings.TrimSpace(records[0][0]) == "position" {
+			records = records[1:]
+		}
+		for _, record := range records {
+			names = append(names, strings.TrimSpace(record[0]))
+		}
+	}
+
+	ps := make([]Position, len(names))
+	for i, name := range names {
+		s, err := ParsePosition(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		ps[i] = Position{s, s.Derive()}
+	}
+	return names, ps, nil
+}
+
+// evaluateMain implements the "evaluate" command: evaluate [-j N] FILE
+//
+// Positions are read from a CSV or JSON file and written with their
+// scores, by default as CSV.
+func evaluateMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("evaluate", flag.ContinueOnError)
+	jobs := flags.Int("j", runtime.NumCPU(), "concurrent workers")
+	format := addFormat(flags, "csv")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: evaluate [-j N] FILE")
+	}
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	names, ps, err := readPositions(f, strings.HasSuffix(flags.Arg(0), ".json"))
+	if err != nil {
+		return err
+	}
+
+	scores := New().EvaluateParallel(ps, *jobs)
+	r := new(Report)
+	tab := r.Table("Positions", "position", "score")
+	for i, name := range names {
+		tab.Add(name, scores[i])
+	}
+	return format.Write(os.Stdout, r)
+}
+
+// batch evaluates one input line, giving a row of the position, score,
+// best moves, and any error.
+func batch(t Minimax, line string) []any {
+	s, err := ParsePosition(line)
+	if err != nil {
+		return []any{line, nil, nil, err.Error()}
+	}
+	m := s.Derive()
+	score := t.Evaluate(s, m)
+	best := []int{}
+	switch {
+	case s.IsComplete(m):
+	case s.NoMoves(m):
+		best = []int{0}
+	default:
+		for _, i := range t.Suggest(s, m) {
+			best = append(best, i+1)
+		}
+	}
+	return []any{line, signed(score), best, nil}
+}
+
+// batchMain implements the "batch" command, answering position strings
+// or IDs read one per line from standard input, one row each, as soon
+// as they arrive. Text rows hold the position, score, and best moves
+// separated by tabs, or the position and an error message in the last
+// column:
+//
+//	...../...../...../...../.....:0	+2	7 9 17 19
+func batchMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("batch", flag.ContinueOnError)
+	format := addFormat(flags, "text")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return errors.New("usage: batch [-format FORMAT] <POSITIONS")
+	}
+	out, err := format.Stream(os.Stdout, "position", "score", "best", "error")
+	if err != nil {
+		return err
+	}
+	t, err := Solved(ctx)
+	if err != nil {
+		return err
+	}
+
+	lines := bufio.NewScanner(os.Stdin)
+	for lines.Scan() && ctx.Err() == nil {
+		line, _, _ := strings.Cut(lines.Text(), "#")
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if err := out.Add(batch(t, line)...); err != nil {
+			return err
+		}
+	}
+	return lines.Err()
+}
@@ -0,0 +1,263 @@
+package bsquare
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bot plays games against the perfect engine through chat commands, one
+// game per channel. The table must already be fully solved.
+type Bot struct {
+	t     Minimax
+	mu    sync.Mutex
+	games map[string]*botGame
+}
+
+type botGame struct {
+	s     State
+	m     Mask
+	human Player
+}
+
+// NewBot returns a chat bot playing from a solved table.
+func NewBot(t Minimax) *Bot {
+	return &Bot{t: t, games: make(map[string]*botGame)}
+}
+
+const botHelp = "commands: new [first|second], SQUARE (1-25), board, help"
+
+// Command processes a chat command for a channel and returns the reply.
+func (b *Bot) Command(channel, text string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	fields := strings.Fields(strings.ToLower(text))
+	if len(fields) == 0 || fields[0] == "help" {
+		return botHelp
+	}
+
+	g := b.games[channel]
+	switch fields[0] {
+	case "new":
+		g = &botGame{}
+		if len(fields) > 1 && fields[1] == "second" {
+			g.human = SecondPlayer
+		}
+		b.games[channel] = g
+		b.advance(g)
+		return b.render(g)
+	case "board":
+		if g == nil {
+			return "no game in progress, start one with: new"
+		}
+		return b.render(g)
+	}
+
+	if fields[0] == "move" && len(fields) > 1 {
+		fields = fields[1:]
+	}
+	i, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return botHelp
+	}
+	if g == nil {
+		return "no game in progress, start one with: new"
+	}
+	s, err := g.s.PlaceChecked(i - 1)
+	if errors.Is(err, ErrGameOver) {
+		return "game over, start another with: new"
+	} else if err != nil {
+		return fmt.Sprintf("%v\n%s", err, b.render(g))
+	}
+	g.s, g.m = s, g.m.Place(i-1)
+	b.advance(g)
+	return b.render(g)
+}
+
+// advance plays engine moves and forced passes until the human is to
+// move or the game is over.
+func (b *Bot) advance(g *botGame) {
+	for !g.s.IsComplete(g.m) {
+		if g.s.NoMoves(g.m) {
+			g.s, g.m = g.s.Pass(), g.m.Pass()
+		} else if g.s.ToMove() == g.human {
+			return
+		} else {
+			i := b.t.Suggest(g.s, g.m)[0]
+			g.s, g.m = g.s.Place(i), g.m.Place(i)
+		}
+	}
+}
+
+// render the game as a Unicode code block: ● first player, ○ second
+// player, · blocked, and legal squares by number.
+func (b *Bot) render(g *botGame) string {
+	var buf strings.Builder
+	buf.WriteString("```\n")
+	for i := 0; i < 5*5; i++ {
+		switch {
+		case g.s>>i&1 == 1:
+			buf.WriteString("  ●")
+		case g.s>>(i+25)&1 == 1:
+			buf.WriteString("  ○")
+		case g.s.ToMove() == g.human && g.m.Valid(i):
+			fmt.Fprintf(&buf, "%3d", i+1)
+		default:
+			buf.WriteString("  ·")
+		}
+		if i%5 == 4 {
+			buf.WriteByte('\n')
+		}
+	}
+	buf.WriteString("```\n")
+	if g.s.IsComplete(g.m) {
+		score := g.s.Score()
+		score *= g.human.Sign()
+		switch {
+		case score > 0:
+			fmt.Fprintf(&buf, "You win by %d!", score)
+		case score < 0:
+			fmt.Fprintf(&buf, "You lose by %d.", -score)
+		default:
+			buf.WriteString("Tie game.")
+		}
+	} else {
+		buf.WriteString("Your move.")
+	}
+	return buf.String()
+}
+
+// SlackHandler serves Slack slash commands, verifying each request
+// against the app's signing secret.
+func (b *Bot) SlackHandler(secret string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<16))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ts := r.Header.Get("X-Slack-Request-Timestamp")
+		unix, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil || time.Since(time.Unix(unix, 0)).Abs() > 5*time.Minute {
+			http.Error(w, "stale request", http.StatusUnauthorized)
+			return
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		fmt.Fprintf(mac, "v0:%s:%s", ts, body)
+		want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+		got := r.Header.Get("X-Slack-Signature")
+		if !hmac.Equal([]byte(want), []byte(got)) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reply := b.Command(r.PostForm.Get("channel_id"), r.PostForm.Get("text"))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"response_type": "in_channel",
+			"text":          reply,
+		})
+	})
+}
+
+// DiscordHandler serves Discord interactions for a slash command with a
+// single string option, verifying each request against the application's
+// hex-encoded public key.
+func (b *Bot) DiscordHandler(publicKey string) (http.Handler, error) {
+	key, err := hex.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid Discord public key")
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<16))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sig, err := hex.DecodeString(r.Header.Get("X-Signature-Ed25519"))
+		msg := append([]byte(r.Header.Get("X-Signature-Timestamp")), body...)
+		if err != nil || !ed25519.Verify(key, msg, sig) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+
+		var req struct {
+			Type      int    `json:"type"`
+			ChannelID string `json:"channel_id"`
+			Data      struct {
+				Options []struct {
+					Value string `json:"value"`
+				} `json:"options"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if req.Type == 1 { // PING
+			w.Write([]byte(`{"type":1}`))
+			return
+		}
+		var text string
+		if len(req.Data.Options) > 0 {
+			text = req.Data.Options[0].Value
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"type": 4, // CHANNEL_MESSAGE_WITH_SOURCE
+			"data": map[string]string{
+				"content": b.Command(req.ChannelID, text),
+			},
+		})
+	}), nil
+}
+
+// botMain implements the "bot" command. Slack is enabled by
+// $SLACK_SIGNING_SECRET at /slack, and Discord by $DISCORD_PUBLIC_KEY at
+// /discord.
+func botMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("bot", flag.ContinueOnError)
+	addr := flags.String("addr", ":8080", "listen address")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	t, err := Solved(ctx)
+	if err != nil {
+		return err
+	}
+	b := NewBot(t)
+
+	mux := http.NewServeMux()
+	if secret := os.Getenv("SLACK_SIGNING_SECRET"); secret != "" {
+		mux.Handle("/slack", b.SlackHandler(secret))
+	}
+	if key := os.Getenv("DISCORD_PUBLIC_KEY"); key != "" {
+		h, err := b.DiscordHandler(key)
+		if err != nil {
+			return err
+		}
+		mux.Handle("/discord", h)
+	}
+	return listenAndServe(ctx, *addr, mux)
+}
@@ -0,0 +1,637 @@
+// Package bsquare solves and plays British Square: game states and their
+// solved tables, engines, and the analysis and game servers. The bsquare
+// command is a thin main around Main.
+package bsquare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"slices"
+)
+
+// State is a game state bitboard encoding the entire game state. No
+// methods actually modify the State, rather return an updated State.
+type State uint64
+
+// Mask efficiently validate moves, mostly behaving like a State. No
+// methods actually modify the Mask, rather return an updated Mask.
+type Mask uint64
+
+// Move errors, wrapped with details of the move.
+var (
+	ErrInvalidSquare = errors.New("invalid square")
+	ErrIllegalMove   = errors.New("illegal move")
+	ErrGameOver      = errors.New("game is over")
+)
+
+// Player identifies one of the two players.
+type Player int
+
+const (
+	FirstPlayer Player = iota
+	SecondPlayer
+)
+
+// TurnPlayer returns the player to move on a 0-indexed turn.
+func TurnPlayer(turn int) Player {
+	return Player(turn % 2)
+}
+
+// Other returns the player's opponent.
+func (p Player) Other() Player {
+	return p ^ 1
+}
+
+// Sign is +1 for the first player and -1 for the second, converting a
+// score between the first player's perspective and this player's.
+func (p Player) Sign() int {
+	return 1 - 2*int(p)
+}
+
+// offset is the bit position of the player's squares in a bitboard.
+func (p Player) offset() int {
+	return int(p) * 25
+}
+
+// String returns the 1-indexed player number.
+func (p Player) String() string {
+	return [...]string{"1", "2"}[p]
+}
+
+// Turn returns the 0-indexed turn count.
+func (s State) Turn() int {
+	return int(s >> 50)
+}
+
+// Turn returns the 0-indexed turn count.
+func (m Mask) Turn() int {
+	return int(m >> 50)
+}
+
+// ToMove returns the player to move.
+func (s State) ToMove() Player {
+	return TurnPlayer(s.Turn())
+}
+
+// ToMove returns the player to move.
+func (m Mask) ToMove() Player {
+	return TurnPlayer(m.Turn())
+}
+
+// Pass the current turn without placing a piece.
+func (s State) Pass() State {
+	turn := s.Turn()
+	bits := s & 0x3ffffffffffff
+	return State(turn+1)<<50 | bits
+}
+
+// Pass the current turn without placing a piece.
+func (m Mask) Pass() Mask {
+	turn := m.Turn()
+	bits := m & 0x3ffffffffffff
+	return Mask(turn+1)<<50 | bits
+}
+
+// Place a piece at a specific position and advance the turn. The square
+// must be on the board, or the turn and pieces are corrupted, and the
+// move is not validated (see PlaceChecked).
+func (s State) Place(i int) State {
+	turn := s.Turn()
+	bits := s & 0x3ffffffffffff
+	bit := State(1) << (s.ToMove().offset() + i)
+	return State(turn+1)<<50 | bits | bit
+}
+
+// PlaceChecked is Place, validating the move under the standard rules.
+func (s State) PlaceChecked(i int) (State, error) {
+	if i < 0 || i >= 25 {
+		return s, fmt.Errorf("%w: %d", ErrInvalidSquare, i+1)
+	}
+	m := s.Derive()
+	if s.IsComplete(m) {
+		return s, ErrGameOver
+	}
+	if !m.Valid(i) {
+		return s, fmt.Errorf("%w: %d", ErrIllegalMove, i+1)
+	}
+	return s.Place(i), nil
+}
+
+// Diff determines the move that led from prev to this state: the square
+// placed and the player who moved, or pass if that player passed (square
+// is then -1). The states are assumed to be consecutive.
+func (s State) Diff(prev State) (square int, player Player, pass bool) {
+	player = prev.ToMove()
+	diff := uint64(s^prev) & 0x3ffffffffffff
+	if diff == 0 {
+		return -1, player, true
+	}
+	return bits.TrailingZeros64(diff) % 25, player, false
+}
+
+// Adjacency computes, for each square of a width by height board in
+// reading order, the mask of squares blocked to the opponent by a piece
+// there: the square itself and its 4-adjacent neighbors.
+func Adjacency(width, height int) []Mask {
+	adj := make([]Mask, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			adj[i] = 1 << i
+			if x > 0 {
+				adj[i] |= 1 << (i - 1)
+			}
+			if x < width-1 {
+				adj[i] |= 1 << (i + 1)
+			}
+			if y > 0 {
+				adj[i] |= 1 << (i - width)
+			}
+			if y < height-1 {
+				adj[i] |= 1 << (i + width)
+			}
+		}
+	}
+	return adj
+}
+
+var masks = Adjacency(5, 5)
+
+// Place a piece at a specific position and advance the turn.
+func (m Mask) Place(i int) Mask {
+	turn := m.Turn()
+	who := m.ToMove()
+	bits := m & 0x3ffffffffffff
+	other := masks[i] << who.Other().offset()
+	self := Mask(1) << (who.offset() + i)
+	return Mask(turn+1)<<50 | bits | other | self
+}
+
+// Derive a validation mask from a game state.
+func (s State) Derive() Mask {
+	var ns [2]int
+	var moves [2][25]int
+	for i := 0; i < 25; i++ {
+		if s>>i&1 == 1 {
+			moves[0][ns[0]] = i
+			ns[0]++
+		}
+		if s>>(i+25)&1 == 1 {
+			moves[1][ns[1]] = i
+			ns[1]++
+		}
+	}
+
+	var m Mask
+	for i := 0; m.Turn() < s.Turn(); i++ {
+		if who := TurnPlayer(i); i/2 < ns[who] {
+			m = m.Place(moves[who][i/2])
+		} else {
+			m = m.Pass()
+		}
+	}
+	return m
+}
+
+// DeriveLoose derives a validation mask from piece placement alone,
+// without replaying a move history. Each piece blocks its own square
+// and, for the opponent, its neighbors. For states arising in play it
+// agrees with Derive, and it remains meaningful for edited or imported
+// positions whose piece counts do not fit the turn.
+func (s State) DeriveLoose() Mask {
+	m := Mask(s.Turn()) << 50
+	for i := 0; i < 25; i++ {
+		if s>>i&1 == 1 {
+			m |= 1<<i | masks[i]<<25
+		}
+		if s>>(i+25)&1 == 1 {
+			m |= masks[i] | 1<<(i+25)
+		}
+	}
+	return m
+}
+
+// Transpose around the 0-6-12-18-24 diagonal.
+func (s State) Transpose() State {
+	return ((s >> 16) & 0x00000020000010) |
+		((s >> 12) & 0x00000410000208) |
+		((s >> 8) & 0x00008208004104) |
+		((s >> 4) & 0x00104104082082) |
+		((s >> 0) & 0xfe082083041041) |
+		((s << 4) & 0x01041040820820) |
+		((s << 8) & 0x00820800410400) |
+		((s << 12) & 0x00410000208000) |
+		((s << 16) & 0x00200000100000)
+}
+
+// Flip vertically.
+func (s State) Flip() State {
+	return ((s >> 20) & 0x0000003e00001f) |
+		((s >> 10) & 0x000007c00003e0) |
+		((s >> 0) & 0xfc00f800007c00) |
+		((s << 10) & 0x001f00000f8000) |
+		((s << 20) & 0x03e00001f00000)
+}
+
+// Mirror horizontally.
+func (s State) Mirror() State {
+	return ((s >> 4) & 0x00210842108421) |
+		((s >> 2) & 0x00421084210842) |
+		((s >> 0) & 0xfc842108421084) |
+		((s << 2) & 0x01084210842108) |
+		((s << 4) & 0x02108421084210)
+}
+
+// Canonicalize to a specific orientation: the least of the eight
+// symmetries. Rather than chaining seven transforms, the symmetries are
+// formed independently from one transpose, so that they can be computed
+// in parallel.
+func (s State) Canonicalize() State {
+	t := s.Transpose()
+	fs, ft := s.Flip(), t.Flip()
+	return min(s, t, fs, ft, s.Mirror(), t.Mirror(), fs.Mirror(), ft.Mirror())
+}
+
+// Orbit returns the number of distinct states among the eight
+// symmetries: how many raw positions the canonical state stands for.
+func (s State) Orbit() int {
+	t := s.Transpose()
+	fs, ft := s.Flip(), t.Flip()
+	forms := [8]State{s, t, fs, ft, s.Mirror(), t.Mirror(), fs.Mirror(), ft.Mirror()}
+	n := 0
+	for i, f := range forms {
+		if !slices.Contains(forms[:i], f) {
+			n++
+		}
+	}
+	return n
+}
+
+// canonicalizeSerial is Canonicalize by a chain of alternating
+// transposes and flips, as a reference.
+func (s State) canonicalizeSerial() State {
+	min := func(a, b State) State {
+		if a < b {
+			return a
+		}
+		return b
+	}
+	c := s
+	s = s.Transpose()
+	c = min(s, c)
+	s = s.Flip()
+	c = min(s, c)
+	s = s.Transpose()
+	c = min(s, c)
+	s = s.Flip()
+	c = min(s, c)
+	s = s.Transpose()
+	c = min(s, c)
+	s = s.Flip()
+	c = min(s, c)
+	s = s.Transpose()
+	c = min(s, c)
+	return c
+}
+
+// Transform is one of the eight board symmetries, numbered in the order
+// visited by Canonicalize: alternating Transpose and Flip, starting with
+// Transpose, applied Transform times.
+type Transform int
+
+// CanonicalTransform returns the transform mapping this state onto its
+// canonical orientation, such that Apply(s) == s.Canonicalize().
+func (s State) CanonicalTransform() Transform {
+	var best Transform
+	c := s
+	for t := Transform(1); t < 8; t++ {
+		if t%2 == 1 {
+			s = s.Transpose()
+		} else {
+			s = s.Flip()
+		}
+		if s < c {
+			c = s
+			best = t
+		}
+	}
+	return best
+}
+
+// Apply the transform to a game state.
+func (t Transform) Apply(s State) State {
+	for i := Transform(0); i < t; i++ {
+		if i%2 == 0 {
+			s = s.Transpose()
+		} else {
+			s = s.Flip()
+		}
+	}
+	return s
+}
+
+// ApplyMask applies the transform to a validation mask.
+func (t Transform) ApplyMask(m Mask) Mask {
+	return Mask(t.Apply(State(m)))
+}
+
+// ApplySquare maps a square index through the transform.
+func (t Transform) ApplySquare(i int) int {
+	x, y := i%5, i/5
+	for j := Transform(0); j < t; j++ {
+		if j%2 == 0 {
+			x, y = y, x
+		} else {
+			y = 4 - y
+		}
+	}
+	return y*5 + x
+}
+
+// Inverse returns the transform that undoes this transform. Odd
+// transforms are reflections and are their own inverse, while even
+// transforms are rotations.
+func (t Transform) Inverse() Transform {
+	return [...]Transform{0, 1, 6, 3, 4, 5, 2, 7}[t]
+}
+
+// Valid indicates if a move is permitted.
+func (m Mask) Valid(i int) bool {
+	if m.Turn() == 0 {
+		return i != 12
+	}
+	return (m >> (m.ToMove().offset() + i) & 1) == 0
+}
+
+// LegalBits returns the squares legal for the player to move at the
+// given turn as a 25-bit mask. Passing a turn other than the mask's own
+// gives the other player's legal squares.
+func (m Mask) LegalBits(turn int) uint32 {
+	if turn == 0 {
+		return 0x1ffffff &^ (1 << 12)
+	}
+	return ^uint32(m>>TurnPlayer(turn).offset()) & 0x1ffffff
+}
+
+// NoMoves indicates if the current player has no moves. Every occupied
+// square is also blocked in the mask for both players, so the mask alone
+// decides.
+func (m Mask) NoMoves() bool {
+	return m>>m.ToMove().offset()&0x1ffffff == 0x1ffffff
+}
+
+// IsComplete indicates if the game has completed (no more moves).
+func (m Mask) IsComplete() bool {
+	return m&0x3ffffffffffff == 0x3ffffffffffff
+}
+
+// NoMoves indicates if the current player has no moves.
+func (s State) NoMoves(m Mask) bool {
+	return m.NoMoves()
+}
+
+// IsComplete indicates if the game has completed (no more moves).
+func (s State) IsComplete(m Mask) bool {
+	return m.IsComplete()
+}
+
+// Print a representation of the game state using the current theme.
+func (s State) Print(w io.Writer, m Mask) error {
+	return theme.PrintBoard(w, s, m, -1)
+}
+
+// InitScore returns the initial minimax score for this turn.
+func (s State) InitScore() int {
+	if s.ToMove() == SecondPlayer {
+		return +25
+	}
+	return -25
+}
+
+// Pieces returns the number of pieces placed by a player.
+func (s State) Pieces(who Player) int {
+	return bits.OnesCount(uint(s >> who.offset() & 0x1ffffff))
+}
+
+// Score computes the final game score.
+func (s State) Score() int {
+	p0 := bits.OnesCount(uint(s & 0x1ffffff))
+	p1 := bits.OnesCount(uint(s >> 25 & 0x1ffffff))
+	return p0 - p1
+}
+
+// Evaluator computes the minimax score of a game state: the final score
+// under perfect play by both players.
+type Evaluator interface {
+	Evaluate(s State, m Mask) int
+}
+
+// solvedStates is the number of canonical states in a full solve, which
+// bounds the entries of any file derived from one.
+const solvedStates = 8_659_987
+
+// Minimax is a game evaluator storing the explored game tree. It always
+// explores to game completion and plays perfectly.
+type Minimax map[State]int8
+
+// New returns an empty minimax tree.
+func New() Minimax {
+	return make(map[State]int8)
+}
+
+// Evaluate the minimax score at a game state.
+func (t Minimax) Evaluate(s State, m Mask) int {
+	score, _ := t.evaluate(context.Background(), Rules{}, nil, nil, s, m)
+	return score
+}
+
+// EvaluateContext is Evaluate, stopping early with the context's error
+// if it is cancelled. The table remains valid after cancellation, with
+// only fully explored positions recorded.
+func (t Minimax) EvaluateContext(ctx context.Context, s State, m Mask) (int, error) {
+	return t.evaluate(ctx, Rules{}, nil, nil, s, m)
+}
+
+// evaluate under the given rules, first consulting an optional read-only
+// base table, and recording new results only in t. Effort is counted in
+// st unless it is nil.
+func (t Minimax) evaluate(ctx context.Context, r Rules, base Minimax, st *SearchStats, s State, m Mask) (int, error) {
+	if st != nil {
+		st.Nodes++
+		st.MaxDepth = max(st.MaxDepth, s.Turn())
+	}
+	s0 := s.Canonicalize()
+	if r.VoluntaryPass {
+		// Passes reach a position at many turn counts, and its value
+		// depends only on the board and the player to move
+		s0 = repetitionKey(s0)
+	}
+	score8, ok := t[s0]
+	if !ok && base != nil {
+		score8, ok = base[s0]
+	}
+	if ok {
+		if st != nil {
+			st.Hits++
+		}
+		return int(score8), nil
+	}
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	if r.IsComplete(s, m) {
+		score := s0.Score()
+		t[s0] = int8(score)
+		return score, nil
+	}
+
+	if r.NoMoves(s, m) {
+		next := t.evaluate
+		if r.VoluntaryPass {
+			next = t.passed
+		}
+		score, err := next(ctx, r, base, st, s.Pass(), m.Pass())
+		if err != nil {
+			return 0, err
+		}
+		t[s0] = int8(score)
+		return score, nil
+	}
+
+	score := s.InitScore()
+	if r.VoluntaryPass {
+		var err error
+		if score, err = t.passed(ctx, r, base, st, s.Pass(), m.Pass()); err != nil {
+			return 0, err
+		}
+	}
+	for b := r.LegalBits(m); b != 0; b &= b - 1 {
+		i := bits.TrailingZeros32(b)
+		tmp, err := t.evaluate(ctx, r, base, st, s.Place(i), m.Place(i))
+		if err != nil {
+			return 0, err
+		}
+		if s.ToMove() == SecondPlayer {
+			if tmp < score {
+				score = tmp // min
+			}
+		} else {
+			if tmp > score {
+				score = tmp // max
+			}
+		}
+	}
+
+	t[s0] = int8(score)
+	return score, nil
+}
+
+// passed evaluates a game state just after a pass under voluntary
+// passes, where passing again repeats the position and ends the game.
+// Its value depends on the pass, so it is not recorded, though the
+// states after each placement are.
+func (t Minimax) passed(ctx context.Context, r Rules, base Minimax, st *SearchStats, s State, m Mask) (int, error) {
+	score := s.Score()
+	if r.RepetitionDraw {
+		score = 0
+	}
+	if r.NoMoves(s, m) {
+		return score, nil
+	}
+	for b := r.LegalBits(m); b != 0; b &= b - 1 {
+		i := bits.TrailingZeros32(b)
+		tmp, err := t.evaluate(ctx, r, base, st, s.Place(i), m.Place(i))
+		if err != nil {
+			return 0, err
+		}
+		if s.ToMove() == SecondPlayer {
+			score = min(score, tmp)
+		} else {
+			score = max(score, tmp)
+		}
+	}
+	return score, nil
+}
+
+// Suggest returns the list of perfect plays from this game state, which
+// is empty when the player to move has no legal moves.
+func (t Minimax) Suggest(s State, m Mask) []int {
+	moves, _ := t.SuggestContext(context.Background(), s, m)
+	return moves
+}
+
+// SuggestContext is Suggest, stopping early with the context's error if
+// it is cancelled.
+func (t Minimax) SuggestContext(ctx context.Context, s State, m Mask) ([]int, error) {
+	var moves []int
+	var best int
+	for b := m.LegalBits(m.Turn()); b != 0; b &= b - 1 {
+		i := bits.TrailingZeros32(b)
+		score, err := t.EvaluateContext(ctx, s.Place(i), m.Place(i))
+		if err != nil {
+			return nil, err
+		}
+		score *= s.ToMove().Sign()
+		if moves == nil || score > best {
+			best = score
+			moves = append(moves[:0], i)
+		} else if score == best {
+			moves = append(moves, i)
+		}
+	}
+	return moves, nil
+}
+
+// Verdict is the winner of a game, or Draw.
+type Verdict int
+
+const (
+	Draw Verdict = iota
+	FirstPlayerWin
+	SecondPlayerWin
+)
+
+func (v Verdict) String() string {
+	return [...]string{"draw", "first player wins", "second player wins"}[v]
+}
+
+// Outcome is the result of a game under perfect play. Margin is the
+// winner's lead in pieces, and zero for a draw.
+type Outcome struct {
+	Verdict Verdict
+	Margin  int
+}
+
+func (o Outcome) String() string {
+	if o.Verdict == Draw {
+		return o.Verdict.String()
+	}
+	return fmt.Sprintf("%v by %d", o.Verdict, o.Margin)
+}
+
+// ScoreOutcome classifies a minimax score.
+func ScoreOutcome(score int) Outcome {
+	switch {
+	case score > 0:
+		return Outcome{FirstPlayerWin, score}
+	case score < 0:
+		return Outcome{SecondPlayerWin, -score}
+	}
+	return Outcome{Draw, 0}
+}
+
+// Outcome returns the result of perfect play from a game state.
+func (t Minimax) Outcome(s State, m Mask) Outcome {
+	return ScoreOutcome(t.Evaluate(s, m))
+}
+
+// Print the scores for each position using the current theme.
+func (t Minimax) Print(w io.Writer, s State, m Mask) error {
+	return theme.PrintScores(w, t, s, m)
+}
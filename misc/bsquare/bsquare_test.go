@@ -0,0 +1,250 @@
+package bsquare
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// TestAdjacency checks the generated adjacency masks against the table
+// originally written by hand.
+func TestAdjacency(t *testing.T) {
+	want := [...]Mask{
+		0x0000023, 0x0000047, 0x000008e, 0x000011c, 0x0000218,
+		0x0000461, 0x00008e2, 0x00011c4, 0x0002388, 0x0004310,
+		0x0008c20, 0x0011c40, 0x0023880, 0x0047100, 0x0086200,
+		0x0118400, 0x0238800, 0x0471000, 0x08e2000, 0x10c4000,
+		0x0308000, 0x0710000, 0x0e20000, 0x1c40000, 0x1880000,
+	}
+	for i, m := range Adjacency(5, 5) {
+		if m != want[i] {
+			t.Fatalf("adjacency mask %d: got %07x, want %07x", i, m, want[i])
+		}
+	}
+}
+
+// TestDerive checks that both mask derivations reproduce the masks
+// tracked through random games.
+func TestDerive(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for g := 0; g < 1000; g++ {
+		var s State
+		var m Mask
+		for !s.IsComplete(m) {
+			if d := s.Derive(); d != m {
+				t.Fatalf("Derive(%v): got %014x, want %014x", s, d, m)
+			}
+			if d := s.DeriveLoose(); d != m {
+				t.Fatalf("DeriveLoose(%v): got %014x, want %014x", s, d, m)
+			}
+			moves := legal(m)
+			if len(moves) == 0 {
+				s, m = s.Pass(), m.Pass()
+			} else {
+				i := moves[r.Intn(len(moves))]
+				s, m = s.Place(i), m.Place(i)
+			}
+		}
+	}
+}
+
+// TestCanonicalize checks the fused symmetries against the serial chain
+// of transforms over random states, and that all eight symmetries share
+// a canonical form.
+func TestCanonicalize(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 100000; n++ {
+		bits := State(r.Uint64())
+		s := bits&0x1ffffff&^(bits>>25) | bits&0x1ffffff<<25&^(bits<<25) | State(r.Intn(64))<<50
+		want := s.canonicalizeSerial()
+		if c := s.Canonicalize(); c != want {
+			t.Fatalf("Canonicalize(%014x): got %014x, want %014x", uint64(s), c, want)
+		}
+		for _, v := range []State{s.Transpose(), s.Flip(), s.Mirror(), s.Flip().Mirror()} {
+			if c := v.Canonicalize(); c != want {
+				t.Fatalf("Canonicalize(%014x): got %014x, want %014x", uint64(v), c, want)
+			}
+		}
+
+		// Mirrored boards exercise the smaller orbits
+		p1 := s & 0x1ffffff
+		for _, v := range []State{s, p1 | p1.Mirror(), p1 | p1.Transpose()} {
+			forms := make(map[State]bool)
+			for tr := Transform(0); tr < 8; tr++ {
+				forms[tr.Apply(v)] = true
+			}
+			if n := v.Orbit(); n != len(forms) {
+				t.Fatalf("Orbit(%014x): got %d, want %d", uint64(v), n, len(forms))
+			}
+		}
+	}
+}
+
+// oracleLegal decides a placement from the rules rather than the masks:
+// an empty square with no opposing piece beside it, and not the center
+// on the opening move.
+func oracleLegal(s State, who Player, i int) bool {
+	if s>>i&1 == 1 || s>>(i+25)&1 == 1 {
+		return false
+	}
+	if s.Turn() == 0 && i == 12 {
+		return false
+	}
+	x, y := i%5, i/5
+	for _, d := range [...][2]int{{-1, 0}, {+1, 0}, {0, -1}, {0, +1}} {
+		nx, ny := x+d[0], y+d[1]
+		if nx < 0 || nx >= 5 || ny < 0 || ny >= 5 {
+			continue
+		}
+		if s>>(who.Other().offset()+ny*5+nx)&1 == 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// oracleMoves lists the squares a player may take per oracleLegal.
+func oracleMoves(s State, who Player) []int {
+	var moves []int
+	for i := 0; i < 25; i++ {
+		if oracleLegal(s, who, i) {
+			moves = append(moves, i)
+		}
+	}
+	return moves
+}
+
+// TestPlaceChecked plays random games by the oracle, checking that
+// checked placement accepts exactly the oracle's moves, rejecting the
+// rest with the matching error.
+func TestPlaceChecked(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for g := 0; g < 1000; g++ {
+		var s State
+		for {
+			who := s.ToMove()
+			over := len(oracleMoves(s, who)) == 0 &&
+				len(oracleMoves(s, who.Other())) == 0
+			for i := -1; i <= 25; i++ {
+				_, err := s.PlaceChecked(i)
+				var want error
+				switch {
+				case i < 0 || i >= 25:
+					want = ErrInvalidSquare
+				case over:
+					want = ErrGameOver
+				case !oracleLegal(s, who, i):
+					want = ErrIllegalMove
+				}
+				if !errors.Is(err, want) {
+					t.Fatalf("PlaceChecked(%v, %d): got %v, want %v", s, i, err, want)
+				}
+			}
+			if over {
+				break
+			}
+			if moves := oracleMoves(s, who); len(moves) == 0 {
+				s = s.Pass()
+			} else {
+				s = s.Place(moves[r.Intn(len(moves))])
+			}
+		}
+	}
+}
+
+// baselineNoMoves and baselineIsComplete are the terminal checks as
+// they were before the mask alone decided them, for comparison.
+func baselineNoMoves(s State, m Mask) bool {
+	who := s.Turn() % 2
+	const M = 0x1ffffff
+	return ((uint64(s)>>(who*25) | uint64(m)>>(who*25)) & M) == M
+}
+
+func baselineIsComplete(s State, m Mask) bool {
+	return ((uint64(s)>>25|uint64(m)>>25)&0x1ffffff) == 0x1ffffff &&
+		((uint64(s)>>0|uint64(m)>>0)&0x1ffffff) == 0x1ffffff
+}
+
+// baselineEvaluate is the solver as it was before the terminal checks
+// from the mask, iteration over legal move bits, and fused symmetries.
+func baselineEvaluate(t Minimax, s State, m Mask) int {
+	s0 := s.canonicalizeSerial()
+	score8, ok := t[s0]
+	if ok {
+		return int(score8)
+	}
+
+	if baselineIsComplete(s, m) {
+		score := s0.Score()
+		t[s0] = int8(score)
+		return score
+	}
+
+	if baselineNoMoves(s, m) {
+		score := baselineEvaluate(t, s.Pass(), m.Pass())
+		t[s0] = int8(score)
+		return score
+	}
+
+	score := s.InitScore()
+	for i := 0; i < 5*5; i++ {
+		if m.Valid(i) {
+			tmp := baselineEvaluate(t, s.Place(i), m.Place(i))
+			if s.Turn()%2 == 1 {
+				score = min(score, tmp)
+			} else {
+				score = max(score, tmp)
+			}
+		}
+	}
+
+	t[s0] = int8(score)
+	return score
+}
+
+// BenchmarkTerminal compares the terminal checks over the positions of
+// a midgame table.
+func BenchmarkTerminal(b *testing.B) {
+	var ps []Position
+	for s := range testTable(b) {
+		ps = append(ps, Position{s, s.Derive()})
+	}
+	checks := []struct {
+		name     string
+		terminal func(State, Mask) bool
+	}{
+		{"mask", func(s State, m Mask) bool { return s.IsComplete(m) || s.NoMoves(m) }},
+		{"baseline", func(s State, m Mask) bool { return baselineIsComplete(s, m) || baselineNoMoves(s, m) }},
+	}
+	for _, c := range checks {
+		b.Run(c.name, func(b *testing.B) {
+			n := 0
+			for b.Loop() {
+				for _, p := range ps {
+					if c.terminal(p.State, p.Mask) {
+						n++
+					}
+				}
+			}
+			if n == 0 {
+				b.Fatal("no terminal positions")
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*len(ps)), "ns/check")
+		})
+	}
+}
+
+// BenchmarkSolve compares full solves by the current and the baseline
+// solver.
+func BenchmarkSolve(b *testing.B) {
+	b.Run("current", func(b *testing.B) {
+		for b.Loop() {
+			New().Evaluate(0, 0)
+		}
+	})
+	b.Run("baseline", func(b *testing.B) {
+		for b.Loop() {
+			baselineEvaluate(New(), 0, 0)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package bsquare
+
+// boundTable is a transposition table of score bounds, either unbounded
+// or a fixed-size hash table with depth-preferred replacement.
+type boundTable struct {
+	m     map[State]bounds
+	slots []boundSlot
+	n     int // used slots, kept by put
+}
+
+type boundSlot struct {
+	key  State
+	b    bounds
+	used bool
+}
+
+// newBoundTable returns a table using at most size bytes, or an
+// unbounded table if size is zero.
+func newBoundTable(size int) *boundTable {
+	if size <= 0 {
+		return &boundTable{m: make(map[State]bounds)}
+	}
+	n := max(size/16, 2) &^ 1 // 16-byte slots, in pairs
+	return &boundTable{slots: make([]boundSlot, n)}
+}
+
+// bucket returns the index of the slot pair for a state. The first slot
+// prefers shallow entries, which are more expensive to recompute, and the
+// second slot always takes the newest entry.
+func (t *boundTable) bucket(s State) int {
+	h := uint64(s) * 0xcca1cee435c5048f
+	h ^= h >> 40
+	return int(h%uint64(len(t.slots)/2)) * 2
+}
+
+func (t *boundTable) get(s State) (bounds, bool) {
+	if t.m != nil {
+		b, ok := t.m[s]
+		return b, ok
+	}
+	i := t.bucket(s)
+	for _, slot := range t.slots[i : i+2] {
+		if slot.used && slot.key == s {
+			return slot.b, true
+		}
+	}
+	return bounds{}, false
+}
+
+func (t *boundTable) put(s State, b bounds) {
+	if t.m != nil {
+		t.m[s] = b
+		return
+	}
+	pair := t.bucket(s)
+	filled := t.filled(pair)
+	i := pair
+	if t.slots[i].used && t.slots[i].key != s {
+		if s.Turn() > t.slots[i].key.Turn() {
+			i++ // keep the shallower entry
+		} else {
+			t.slots[i+1] = t.slots[i]
+		}
+	}
+	t.slots[i] = boundSlot{s, b, true}
+	t.n += t.filled(pair) - filled
+}
+
+// filled returns the number of used slots in the pair at index i.
+func (t *boundTable) filled(i int) int {
+	n := 0
+	for _, slot := range t.slots[i : i+2] {
+		if slot.used {
+			n++
+		}
+	}
+	return n
+}
+
+// len returns the number of entries in the table.
+func (t *boundTable) len() int {
+	if t.m != nil {
+		return len(t.m)
+	}
+	return t.n
+}
@@ -0,0 +1,26 @@
+package bsquare
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestBoundTableLen checks the running count of a bounded table against
+// a scan of its slots as entries collide and replace each other.
+func TestBoundTableLen(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	table := newBoundTable(64 * 16)
+	for k := 0; k < 10000; k++ {
+		s := State(r.Intn(200)) | State(r.Intn(50))<<50
+		table.put(s, bounds{})
+		n := 0
+		for _, slot := range table.slots {
+			if slot.used {
+				n++
+			}
+		}
+		if got := table.len(); got != n {
+			t.Fatalf("put %d: len %d, want %d", k, got, n)
+		}
+	}
+}
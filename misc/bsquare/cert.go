@@ -0,0 +1,217 @@
+package bsquare
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// Certificate is an independently verifiable proof of the value of the
+// game from Start. It holds a strategy for each player: at every
+// position reachable against all opposing replies, the move to play,
+// keyed by canonical state. The prover's strategy must reach at least
+// Value for the first player, and the refuter's strategy at most Value.
+type Certificate struct {
+	Start State
+	Value int
+	First map[State]int8 // first player's canonical moves
+	Other map[State]int8 // second player's canonical moves
+}
+
+// Certify extracts a certificate for the value of a game state from a
+// solved table, using each player's minimal policy as their strategy.
+func (t Minimax) Certify(s State, m Mask) *Certificate {
+	return &Certificate{
+		Start: s,
+		Value: t.Evaluate(s, m),
+		First: t.Policy(s, m, FirstPlayer).Moves,
+		Other: t.Policy(s, m, SecondPlayer).Moves,
+	}
+}
+
+// Verify checks the certificate from its start using only the game
+// rules, returning an error if either strategy fails.
+func (c *Certificate) Verify() error {
+	if err := c.Start.Validate(); err != nil {
+		return fmt.Errorf("start position: %v", err)
+	}
+	s, m := c.Start, c.Start.Derive()
+	seen := make(map[State]bool)
+	if err := c.verify(s, m, FirstPlayer, c.First, seen); err != nil {
+		return fmt.Errorf("first player strategy: %v", err)
+	}
+	clear(seen)
+	if err := c.verify(s, m, SecondPlayer, c.Other, seen); err != nil {
+		return fmt.Errorf("second player strategy: %v", err)
+	}
+	return nil
+}
+
+func (c *Certificate) verify(s State, m Mask, who Player, moves map[State]int8, seen map[State]bool) error {
+	canon := s.Canonicalize()
+	if seen[canon] {
+		return nil
+	}
+	seen[canon] = true
+
+	if s.IsComplete(m) {
+		score := s.Score()
+		if who == FirstPlayer && score < c.Value || who == SecondPlayer && score > c.Value {
+			return fmt.Errorf("game %s ends %+d", s, score)
+		}
+		return nil
+	}
+	if s.NoMoves(m) {
+		return c.verify(s.Pass(), m.Pass(), who, moves, seen)
+	}
+	if s.ToMove() != who {
+		for _, i := range legal(m) {
+			if err := c.verify(s.Place(i), m.Place(i), who, moves, seen); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	j, ok := moves[canon]
+	if !ok {
+		return fmt.Errorf("no move for %s", s)
+	} else if j < 0 || j >= 25 {
+		return fmt.Errorf("invalid move for %s", s)
+	}
+	i := s.CanonicalTransform().Inverse().ApplySquare(int(j))
+	if !m.Valid(i) {
+		return fmt.Errorf("%w %d for %s", ErrIllegalMove, i+1, s)
+	}
+	return c.verify(s.Place(i), m.Place(i), who, moves, seen)
+}
+
+var certMagic = [4]byte{'B', 'S', 'Q', 'C'}
+
+// WriteTo writes the certificate in binary: a magic number, the little
+// endian start state and the value, then each strategy as a count
+// followed by sorted 9-byte entries of the little endian canonical state
+// and the move.
+func (c *Certificate) WriteTo(w io.Writer) (int64, error) {
+	buf := bufio.NewWriter(w)
+	buf.Write(certMagic[:])
+	buf.Write(binary.LittleEndian.AppendUint64(nil, uint64(c.Start)))
+	buf.WriteByte(byte(int8(c.Value)))
+	n := int64(13)
+	for _, moves := range []map[State]int8{c.First, c.Other} {
+		states := make([]State, 0, len(moves))
+		for s := range moves {
+			states = append(states, s)
+		}
+		sort.Slice(states, func(i, j int) bool { return states[i] < states[j] })
+		buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(states))))
+		n += 4
+		for _, s := range states {
+			var entry [9]byte
+			binary.LittleEndian.PutUint64(entry[:], uint64(s))
+			entry[8] = byte(moves[s])
+			buf.Write(entry[:])
+			n += 9
+		}
+	}
+	return n, buf.Flush()
+}
+
+// ReadCertificate reads a certificate written by WriteTo.
+func ReadCertificate(r io.Reader) (*Certificate, error) {
+	buf := bufio.NewReader(r)
+	var header [13]byte
+	if _, err := io.ReadFull(buf, header[:]); err != nil {
+		return nil, err
+	}
+	if [4]byte(header[:4]) != certMagic {
+		return nil, errors.New("not a certificate")
+	}
+	c := &Certificate{
+		Start: State(binary.LittleEndian.Uint64(header[4:])),
+		Value: int(int8(header[12])),
+	}
+	for _, moves := range []*map[State]int8{&c.First, &c.Other} {
+		var count [4]byte
+		if _, err := io.ReadFull(buf, count[:]); err != nil {
+			return nil, err
+		}
+		n := binary.LittleEndian.Uint32(count[:])
+		if n > solvedStates {
+			return nil, errors.New("certificate too large")
+		}
+		*moves = make(map[State]int8)
+		for i := uint32(0); i < n; i++ {
+			var entry [9]byte
+			if _, err := io.ReadFull(buf, entry[:]); err != nil {
+				return nil, err
+			}
+			(*moves)[State(binary.LittleEndian.Uint64(entry[:]))] = int8(entry[8])
+		}
+	}
+	return c, nil
+}
+
+// certifyMain implements the "certify" command: certify -o FILE [MOVES]
+func certifyMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("certify", flag.ContinueOnError)
+	out := flags.String("o", "", "output file (required)")
+	in := addInput(flags)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return errors.New("usage: certify -o FILE [MOVES]")
+	}
+	s, m, err := in.position(flags.Args())
+	if err != nil {
+		return err
+	}
+	t, err := Solved(ctx)
+	if err != nil {
+		return err
+	}
+	c := t.Certify(s, m)
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := c.WriteTo(f); err != nil {
+		return err
+	}
+	fmt.Printf("Value %+d: %d first player moves, %d second player moves\n",
+		c.Value, len(c.First), len(c.Other))
+	return f.Close()
+}
+
+// verifyMain implements the "verify" command: verify FILE
+func verifyMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: verify FILE")
+	}
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	c, err := ReadCertificate(f)
+	if err != nil {
+		return err
+	}
+	if err := c.Verify(); err != nil {
+		return err
+	}
+	fmt.Printf("Verified: game value from %v is %v\n", c.Start, ScoreOutcome(c.Value))
+	return nil
+}
@@ -0,0 +1,43 @@
+package bsquare
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestCertificate certifies a midgame position, then checks that the
+// certificate verifies after a round trip, and not with a better value.
+func TestCertificate(t *testing.T) {
+	s, err := ParsePosition("...o./.o.../..o../xx.x./.....:6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New().Certify(s, s.Derive())
+
+	var buf bytes.Buffer
+	if _, err := c.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	r, err := ReadCertificate(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Start != s || r.Value != c.Value {
+		t.Fatalf("read start %v value %+d, want %v %+d", r.Start, r.Value, s, c.Value)
+	}
+	if err := r.Verify(); err != nil {
+		t.Error(err)
+	}
+	r.Value++
+	if err := r.Verify(); err == nil {
+		t.Error("verified a value one better")
+	}
+
+	// A corrupt count must not be trusted for an allocation
+	hostile := append([]byte(nil), certMagic[:]...)
+	hostile = append(hostile, make([]byte, 9)...)
+	hostile = append(hostile, 0xff, 0xff, 0xff, 0xff)
+	if _, err := ReadCertificate(bytes.NewReader(hostile)); err == nil {
+		t.Error("read a certificate with 2^32-1 entries")
+	}
+}
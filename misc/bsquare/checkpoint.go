@@ -0,0 +1,84 @@
+package bsquare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// SolveCheckpointed solves from a game state like EvaluateContext, saving
+// the partial table to path in the compact format every interval and
+// when cancelled. A solve resumes from an existing checkpoint, which is
+// removed once the solve completes. Only fully explored positions are
+// recorded, so work in progress at each checkpoint is repeated.
+func (t Minimax) SolveCheckpointed(ctx context.Context, s State, m Mask, path string, interval time.Duration) (int, error) {
+	score, _, err := t.solveCheckpointed(ctx, s, m, path, interval)
+	return score, err
+}
+
+// solveCheckpointed is SolveCheckpointed, also reporting the search
+// effort summed over every step of this run.
+func (t Minimax) solveCheckpointed(ctx context.Context, s State, m Mask, path string, interval time.Duration) (int, SearchStats, error) {
+	var effort SearchStats
+	if err := t.loadCheckpoint(path); err != nil {
+		return 0, effort, err
+	}
+	for {
+		step, cancel := context.WithTimeout(ctx, interval)
+		score, st, err := t.evaluateStats(step, s, m)
+		cancel()
+		effort.Add(st)
+		if err == nil {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return 0, effort, err
+			}
+			return score, effort, nil
+		}
+		if err := t.saveCheckpoint(path); err != nil {
+			return 0, effort, err
+		}
+		if ctx.Err() != nil {
+			return 0, effort, fmt.Errorf("solve stopped after %d states, saved to %s: %w",
+				len(t), path, ctx.Err())
+		}
+	}
+}
+
+// loadCheckpoint merges a checkpoint into the table, if it exists.
+func (t Minimax) loadCheckpoint(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	saved, err := ReadCompact(f)
+	if err != nil {
+		return fmt.Errorf("checkpoint %s: %w", path, err)
+	}
+	for s, score := range saved {
+		t[s] = score
+	}
+	return nil
+}
+
+// saveCheckpoint atomically replaces the checkpoint with the table.
+func (t Minimax) saveCheckpoint(path string) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := t.WriteCompact(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
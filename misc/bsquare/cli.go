@@ -0,0 +1,738 @@
+package bsquare
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// command is a CLI subcommand.
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"solve", "solve the game and print statistics", solveMain},
+		{"play", "play interactively against the engine", playMain},
+		{"analyze", "print move scores for a position", analyzeMain},
+		{"edit", "set up a position to analyze or play", editMain},
+		{"serve", "host games over HTTP", serveMain},
+		{"bench", "time the full solve", benchMain},
+		{"export", "write the solved table", exportMain},
+		{"evaluate", "evaluate positions listed in a CSV or JSON file", evaluateMain},
+		{"batch", "evaluate positions read line by line from stdin", batchMain},
+		{"certify", "write a proof certificate of the game value", certifyMain},
+		{"verify", "check a proof certificate", verifyMain},
+		{"policy", "write a perfect-play policy for one player", policyMain},
+		{"tree", "print the opening tree", treeMain},
+		{"perfect", "count and sample the perfect games", perfectMain},
+		{"heatmap", "shade squares by statistics over solved positions", heatmapMain},
+		{"sgf", "write a game as an SGF record", sgfMain},
+		{"replay", "step through a game with optional commentary", replayMain},
+		{"query", "find solved positions matching predicates", queryMain},
+		{"search", "evaluate a game with alpha-beta search", searchMain},
+		{"pns", "prove a first player win with proof-number search", pnsMain},
+		{"match", "play engines against each other", matchMain},
+		{"supply", "solve piece-supply variants", supplyMain},
+		{"sweep", "solve and compare rule variants", sweepMain},
+		{"sensitivity", "compare game values over a grid of rules", sensitivityMain},
+		{"bot", "serve Slack and Discord chat bots", botMain},
+		{"arena", "play as a bot over a JSON line protocol", arenaMain},
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: bsquare COMMAND [FLAGS] [ARGS]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintln(os.Stderr, "\nPositions are given as trailing moves (squares 1-25), a")
+	fmt.Fprintln(os.Stderr, "position string or ID with -p, or a game file with -g.")
+}
+
+// Main runs the command line interface on the arguments following the
+// program name, returning the exit status.
+func Main(args []string) int {
+	if len(args) < 1 {
+		usage()
+		return 2
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			var err error
+			if config, err = LoadSettings(); err != nil {
+				fmt.Fprintln(os.Stderr, "bsquare:", err)
+				return 1
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			err = c.run(ctx, args[1:])
+			stop()
+			if errors.Is(err, flag.ErrHelp) {
+				return 2
+			} else if err != nil {
+				fmt.Fprintln(os.Stderr, "bsquare:", err)
+				return 1
+			}
+			return 0
+		}
+	}
+	usage()
+	return 2
+}
+
+// tablebase loads a precomputed solved table, if built in.
+var tablebase func() (Minimax, error)
+
+// Solved returns a fully-solved minimax tree, or an error if the solve
+// is cancelled. A built-in tablebase is used when available, and then
+// the table cache file when configured, which a solve fills.
+func Solved(ctx context.Context) (Minimax, error) {
+	t, _, err := solvedStats(ctx)
+	return t, err
+}
+
+// solvedStats is Solved, also reporting the effort of getting the table.
+// A loaded table visits no nodes, and only takes time.
+func solvedStats(ctx context.Context) (Minimax, SearchStats, error) {
+	start := time.Now()
+	loaded := func(t Minimax) SearchStats {
+		return SearchStats{Duration: time.Since(start), TableSize: len(t)}
+	}
+	if tablebase != nil {
+		t, err := tablebase()
+		if err == nil {
+			return t, loaded(t), nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, SearchStats{}, fmt.Errorf("tablebase: %w", err)
+		}
+	}
+	path := tableCache()
+	if path == "" {
+		return solve(ctx)
+	}
+	t := New()
+	if err := t.loadCheckpoint(path); err != nil {
+		return nil, SearchStats{}, err
+	}
+	if _, ok := t[0]; ok {
+		return t, loaded(t), nil // the root is stored last
+	}
+	t, st, err := solve(ctx)
+	if err != nil {
+		return nil, st, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, st, err
+	}
+	return t, st, t.saveCheckpoint(path)
+}
+
+// solve fully solves a new minimax tree, reporting the effort, or
+// returns an error if the solve is cancelled.
+func solve(ctx context.Context) (Minimax, SearchStats, error) {
+	t := New()
+	_, st, err := t.evaluateStats(ctx, 0, 0)
+	if err != nil {
+		return nil, st, fmt.Errorf("solve stopped after %d states: %w", len(t), err)
+	}
+	return t, st, nil
+}
+
+// input holds the position flags shared by commands.
+type input struct {
+	pos   *string
+	file  *string
+	rules *string // nil unless the command supports other rules
+}
+
+// addInput registers the position flags on a command's flag set.
+func addInput(flags *flag.FlagSet) input {
+	return input{
+		pos:  flags.String("p", "", "position string or ID"),
+		file: flags.String("g", "", "game file (SGF with a .sgf extension)"),
+	}
+}
+
+// addRules registers the rules flag, for commands that support rules
+// other than the standard, which apply to a game given as moves.
+func (in *input) addRules(flags *flag.FlagSet) {
+	in.rules = flags.String("rules", "standard", "rules of a game given as moves")
+}
+
+// game returns the game selected by a game file or by moves in args.
+func (in input) game(args []string) (*Game, error) {
+	rec, err := in.record(args)
+	if err != nil {
+		return nil, err
+	}
+	return &rec.Game, nil
+}
+
+// record returns the game record selected by a game file, which is read
+// as SGF given a ".sgf" extension, or by moves in args.
+func (in input) record(args []string) (*Record, error) {
+	if *in.file == "" {
+		g := new(Game)
+		if in.rules != nil {
+			var err error
+			if g.Rules, err = ParseRules(*in.rules); err != nil {
+				return nil, err
+			}
+		}
+		if err := g.Extend(args); err != nil {
+			return nil, err
+		}
+		return NewRecord(g), nil
+	}
+	if len(args) > 0 {
+		return nil, errors.New("both a game file and moves given")
+	}
+	f, err := os.Open(*in.file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if strings.HasSuffix(strings.ToLower(*in.file), ".sgf") {
+		return ParseSGF(f)
+	}
+	return ParseRecord(f)
+}
+
+// position returns the position selected by the flags or by moves in
+// args, defaulting to the empty board.
+func (in input) position(args []string) (State, Mask, error) {
+	if *in.pos != "" {
+		if *in.file != "" || len(args) > 0 {
+			return 0, 0, errors.New("multiple positions given")
+		}
+		s, err := ParsePosition(*in.pos)
+		return s, s.Derive(), err
+	}
+	g, err := in.game(args)
+	if err != nil {
+		return 0, 0, err
+	}
+	s, m := g.Position()
+	return s, m, nil
+}
+
+func solveMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("solve", flag.ContinueOnError)
+	census := flags.Bool("census", false, "print positions by turn")
+	csv := flags.Bool("csv", false, "print only the census, as CSV (same as -census -format csv)")
+	checkpoint := flags.String("checkpoint", "", "save progress to and resume from this file")
+	interval := flags.Duration("interval", time.Minute, "time between checkpoints")
+	retrograde := flags.Bool("retrograde", false, "enumerate positions by turn, then score backwards")
+	disk := flags.String("disk", "", "solve into a disk table in this file, resuming it if it exists")
+	cache := flags.Int("cache", 64, "disk table cache size in MiB")
+	countGames := flags.Bool("games", false, "also count every distinct game, not merging symmetric games")
+	format := addFormat(flags, "text")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *csv {
+		*census, *format = true, "csv"
+	}
+	var games *GameCounts
+	if *countGames {
+		layers, err := Enumerate(ctx, 0)
+		if err != nil {
+			return fmt.Errorf("enumeration stopped: %w", err)
+		}
+		counts, err := layers.CountGames(ctx)
+		if err != nil {
+			return fmt.Errorf("game count stopped: %w", err)
+		}
+		games = &counts
+	}
+	if *disk != "" {
+		if *census {
+			return errors.New("no census for disk tables")
+		}
+		return solveDisk(ctx, *disk, *cache<<20, *retrograde, games, *format)
+	}
+
+	var t Minimax
+	var effort SearchStats
+	var err error
+	if *checkpoint != "" {
+		t = New()
+		_, effort, err = t.solveCheckpointed(ctx, 0, 0, *checkpoint, *interval)
+	} else if *retrograde {
+		t, effort, err = SolveRetrogradeStats(ctx)
+	} else {
+		t, effort, err = solvedStats(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	r := new(Report)
+	solveSummary(r, len(t), t.Evaluate(0, 0), games, func(fn func(State, int8)) error {
+		for s, score := range t {
+			fn(s, score)
+		}
+		return nil
+	})
+	effort.AddTo(r)
+	st := t.Stats()
+	r.AddText("Table memory", st.Bytes, fmt.Sprintf("%d MiB (approximate)", st.Bytes>>20))
+	if *census {
+		censusTable(r, t.Census())
+	}
+	return format.Write(os.Stdout, r)
+}
+
+// solveDisk solves into a disk table, depth-first or retrograde, keeping
+// it for resuming if the solve is interrupted.
+func solveDisk(ctx context.Context, path string, cache int, retrograde bool, games *GameCounts, format Format) error {
+	const capacity = 9_000_000 // entries, above the solvedStates of a solve
+	t, err := OpenDiskTable(path, capacity, cache)
+	if err != nil {
+		return err
+	}
+	var value int
+	var effort SearchStats
+	if retrograde {
+		value, effort, err = SolveRetrogradeStore(ctx, t)
+	} else {
+		value, effort, err = SolveStoreStats(ctx, t, 0, 0)
+	}
+	if err != nil {
+		if cerr := t.Close(); cerr != nil {
+			return cerr
+		}
+		return fmt.Errorf("solve stopped after %d states, saved to %s: %w", t.Len(), path, err)
+	}
+	r := new(Report)
+	if err := solveSummary(r, t.Len(), value, games, t.Range); err != nil {
+		t.Close()
+		return err
+	}
+	effort.AddTo(r)
+	if err := t.Close(); err != nil {
+		return err
+	}
+	return format.Write(os.Stdout, r)
+}
+
+// solveSummary adds a solved table's size, value, and endings to a report.
+// Endings are counted both as canonical positions, one per symmetry
+// class, and as raw positions, counting each orientation, followed by
+// the distinct games when counted.
+func solveSummary(r *Report, n, value int, games *GameCounts, each func(func(State, int8)) error) error {
+	var canonical, raw GameCounts
+	err := each(func(s State, score int8) {
+		if s.IsComplete(s.Derive()) {
+			canonical.add(s, 1)
+			raw.add(s, uint64(s.Orbit()))
+		}
+	})
+	r.Add("Table entries", n)
+	r.AddText("Game value", signed(value), ScoreOutcome(value).String())
+	tab := r.Table("Endings", "counting", "total", "player 1 wins", "player 2 wins", "ties")
+	tab.Add("canonical endings", canonical.Total(), canonical.P1Wins, canonical.P2Wins, canonical.Ties)
+	tab.Add("raw endings", raw.Total(), raw.P1Wins, raw.P2Wins, raw.Ties)
+	if games != nil {
+		tab.Add("games", games.Total(), games.P1Wins, games.P2Wins, games.Ties)
+	}
+	return err
+}
+
+// CensusRow summarizes the canonical positions at one turn, with results
+// from the perspective of the player to move.
+type CensusRow struct {
+	Turn     int
+	Total    int
+	Wins     int
+	Losses   int
+	Draws    int
+	MaxScore int // largest absolute score
+}
+
+// Census tallies the table's positions by turn.
+func (t Minimax) Census() []CensusRow {
+	var rows []CensusRow
+	for s, score8 := range t {
+		turn := s.Turn()
+		for len(rows) <= turn {
+			rows = append(rows, CensusRow{Turn: len(rows)})
+		}
+		r := &rows[turn]
+		score := int(score8) * s.ToMove().Sign()
+		r.Total++
+		if score > 0 {
+			r.Wins++
+		} else if score < 0 {
+			r.Losses++
+		} else {
+			r.Draws++
+		}
+		r.MaxScore = max(r.MaxScore, score, -score)
+	}
+	return rows
+}
+
+// censusTable adds the census rows with positions to a report.
+func censusTable(r *Report, rows []CensusRow) {
+	tab := r.Table("Census", "turn", "positions", "wins", "losses", "draws", "max score")
+	for _, c := range rows {
+		if c.Total > 0 {
+			tab.Add(c.Turn, c.Total, c.Wins, c.Losses, c.Draws, c.MaxScore)
+		}
+	}
+}
+
+// analysisReport reports the score map, board, and suggestions for a position.
+func analysisReport(t Minimax, s State, m Mask) *Report {
+	r := &Report{Board: &Diagram{s, m, t, -1}}
+	score := t.Evaluate(s, m)
+	if s.IsComplete(m) {
+		r.AddText("Score", signed(score), fmt.Sprintf("%+d (game over)", score))
+		return r
+	}
+	r.AddText("Score", signed(score), fmt.Sprintf("%+d (%v)", score, t.Outcome(s, m)))
+	moves := t.Suggest(s, m)
+	if len(moves) == 0 {
+		r.AddText("Suggestions", []int{0}, "0 (pass)")
+		return r
+	}
+	squares := make([]int, len(moves))
+	for k, i := range moves {
+		squares[k] = i + 1
+	}
+	r.Add("Suggestions", squares)
+	return r
+}
+
+// analyze prints the analysis of a position as text.
+func analyze(w io.Writer, t Minimax, s State, m Mask) {
+	Format("text").Write(w, analysisReport(t, s, m))
+}
+
+func analyzeMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	explain := flags.Bool("explain", false, "analyze each open square for both players")
+	assessment := flags.Bool("assess", false, "assess pieces, open squares, and guaranteed scores")
+	in := addInput(flags)
+	display := addTheme(flags)
+	format := addFormat(flags, "text")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if err := display.apply(); err != nil {
+		return err
+	}
+	s, m, err := in.position(flags.Args())
+	if err != nil {
+		return err
+	}
+	t, err := Solved(ctx)
+	if err != nil {
+		return err
+	}
+	r := analysisReport(t, s, m)
+	if *assessment {
+		assessmentTable(r, t.Assessment(s, m))
+	}
+	if *explain {
+		explainTable(r, t.Explain(s, m))
+	}
+	return format.Write(os.Stdout, r)
+}
+
+// feedback explains a suboptimal move: the swing in score from the
+// mover's point of view and the perfect alternatives.
+func feedback(w io.Writer, t Minimax, s State, m Mask, i int) {
+	before := t.Evaluate(s, m)
+	after := t.Evaluate(child(s, m, i))
+	swing := (after - before) * s.ToMove().Sign()
+	if swing >= 0 {
+		return
+	}
+	name := "0 (pass)"
+	if i >= 0 {
+		name = strconv.Itoa(i + 1)
+	}
+	fmt.Fprintf(w, "Mistake: %s changes the score from %+d to %+d.",
+		name, before, after)
+	if best := t.Suggest(s, m); len(best) > 0 {
+		fmt.Fprint(w, " Better:")
+		for _, j := range best {
+			fmt.Fprintf(w, " %d", j+1)
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// playMain implements the "play" command. Against the engine only the
+// board is shown, with "hint" showing the move scores and suggestions,
+// while without an engine the full analysis is shown every turn.
+func playMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("play", flag.ContinueOnError)
+	side := flags.Int("side", 2, "player played by the engine (1, 2, or 0: none)")
+	coach := flags.Bool("coach", false, "explain suboptimal moves")
+	name := flags.String("engine", "perfect", "opponent engine ("+engineUsage()+")")
+	opts := addEngineOptions(flags)
+	level := flags.Int("level", 0, "engine difficulty from 1 to 10, replacing -engine")
+	seed := addSeed(flags)
+	in := addInput(flags)
+	display := addTheme(flags)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if err := display.apply(); err != nil {
+		return err
+	}
+	if *side < 0 || *side > 2 {
+		return fmt.Errorf("invalid side: %d", *side)
+	}
+	s0, m0, err := in.position(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	t, err := Solved(ctx)
+	if err != nil {
+		return err
+	}
+	if *level != 0 {
+		*name = fmt.Sprintf("level%d", *level)
+	}
+	seed.apply()
+	engine, err := NewEngine(*name, t, opts)
+	if err != nil {
+		return err
+	}
+	stdin := bufio.NewScanner(os.Stdin)
+	return play(stdin, t, engine, *side, *coach, s0, m0)
+}
+
+// play runs an interactive game from a position until input ends or the
+// player quits, with the engine playing side (1, 2, or 0: none).
+func play(stdin *bufio.Scanner, t Minimax, engine Engine, side int, coach bool, s0 State, m0 Mask) error {
+	s, m := s0, m0
+	last := -1
+	fmt.Println("(Positions are 1-25, 0 passes, -1 restarts, \"hint\" hints, \"quit\" quits.)")
+	for {
+		if !s.IsComplete(m) && side != 0 && s.ToMove() == Player(side-1) {
+			last = engine.Move(s, m)
+			s, m = child(s, m, last)
+			continue
+		}
+		if side == 0 {
+			analyze(os.Stdout, t, s, m)
+		} else {
+			theme.PrintBoard(os.Stdout, s, m, last)
+			if s.IsComplete(m) {
+				fmt.Printf("Game over! Score: %d\n", s.Score())
+			}
+		}
+
+		for {
+			fmt.Print(">>> ")
+			if !stdin.Scan() {
+				fmt.Println()
+				return stdin.Err()
+			}
+			input := strings.TrimSpace(stdin.Text())
+			if input == "hint" {
+				analyze(os.Stdout, t, s, m)
+				continue
+			} else if input == "quit" {
+				return nil
+			}
+			i, err := strconv.Atoi(input)
+			move := i - 1
+			if err != nil {
+				fmt.Println("INVALID")
+				continue
+			} else if i == -1 {
+				s, m, last = s0, m0, -1
+				break
+			} else if s.IsComplete(m) || i < 0 || i > 25 {
+				fmt.Println("INVALID")
+				continue
+			} else if i > 0 && !m.Valid(move) || i == 0 && !s.NoMoves(m) {
+				fmt.Println("INVALID")
+				continue
+			}
+			if coach {
+				feedback(os.Stdout, t, s, m, move)
+			}
+			s, m = child(s, m, move)
+			last = move
+			break
+		}
+	}
+}
+
+func benchMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	n := flags.Int("n", 1, "number of solves")
+	solvers := flags.String("solver", "minimax", "comma-separated solvers (minimax, retrograde, store)")
+	format := addFormat(flags, "text")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	var runs []func() (Minimax, SearchStats, error)
+	names := strings.Split(*solvers, ",")
+	for _, name := range names {
+		switch name {
+		case "minimax":
+			runs = append(runs, func() (Minimax, SearchStats, error) { return solve(ctx) })
+		case "retrograde":
+			runs = append(runs, func() (Minimax, SearchStats, error) { return SolveRetrogradeStats(ctx) })
+		case "store":
+			runs = append(runs, func() (Minimax, SearchStats, error) {
+				t := New()
+				_, st, err := SolveStoreStats(ctx, t, 0, 0)
+				return t, st, err
+			})
+		default:
+			return fmt.Errorf("unknown solver: %q", name)
+		}
+	}
+	r := new(Report)
+	solves := r.Table("Solves", "solver", "states", "nodes", "hits", "max depth", "time", "states/s")
+	var t Minimax
+	for i := 0; i < *n; i++ {
+		for k, run := range runs {
+			var st SearchStats
+			var err error
+			if t, st, err = run(); err != nil {
+				return err
+			}
+			solves.Add(names[k], st.TableSize, st.Nodes, st.Hits, st.MaxDepth, st.Duration,
+				int(float64(st.TableSize)/st.Duration.Seconds()))
+		}
+	}
+
+	// Terminal detection over every solved position
+	var ps []Position
+	for s := range t {
+		ps = append(ps, Position{s, s.Derive()})
+	}
+	start := time.Now()
+	terminal := 0
+	for _, p := range ps {
+		if p.State.IsComplete(p.Mask) || p.State.NoMoves(p.Mask) {
+			terminal++
+		}
+	}
+	elapsed := time.Since(start)
+	r.Add("Terminal checks", len(ps))
+	r.Add("Terminal", terminal)
+	r.Add("Check time", elapsed)
+	r.Add("ns/check", float64(elapsed.Nanoseconds())/float64(len(ps)))
+
+	// Canonicalization, fused against the serial chain of transforms
+	canon := r.Table("Canonicalize", "method", "time", "ns/state")
+	for _, c := range []struct {
+		name string
+		f    func(State) State
+	}{
+		{"fused", State.Canonicalize},
+		{"serial", State.canonicalizeSerial},
+	} {
+		var sum State
+		start := time.Now()
+		for _, p := range ps {
+			sum += c.f(p.State)
+		}
+		elapsed := time.Since(start)
+		if sum == 0 {
+			return errors.New("canonicalize: empty table")
+		}
+		canon.Add(c.name, elapsed, float64(elapsed.Nanoseconds())/float64(len(ps)))
+	}
+
+	// Table serialization formats
+	sizes := r.Table("Formats", "format", "bytes", "bytes/entry", "write", "read")
+	formats := []struct {
+		name  string
+		write func(io.Writer) error
+		read  func(io.Reader) (Minimax, error)
+	}{
+		{"raw", func(w io.Writer) error { _, err := t.WriteTo(w); return err }, ReadTable},
+		{"compact", t.WriteCompact, ReadCompact},
+	}
+	for _, f := range formats {
+		var buf bytes.Buffer
+		start := time.Now()
+		if err := f.write(&buf); err != nil {
+			return err
+		}
+		written := time.Since(start)
+		size := buf.Len()
+		start = time.Now()
+		got, err := f.read(&buf)
+		if err != nil {
+			return err
+		} else if len(got) != len(t) {
+			return fmt.Errorf("%s: read %d of %d entries", f.name, len(got), len(t))
+		}
+		sizes.Add(f.name, size, float64(size)/float64(len(t)), written, time.Since(start))
+	}
+	return format.Write(os.Stdout, r)
+}
+
+// exportMain writes the solved table in the raw format of Minimax.WriteTo
+// or the compact format of Minimax.WriteCompact.
+func exportMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	out := flags.String("o", "", "output file (default: stdout)")
+	encoding := flags.String("table", "raw", "table encoding (raw, compact)")
+	maxTurn := flags.Int("max-turn", -1, "leave out positions past this turn (-1: none)")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *encoding != "raw" && *encoding != "compact" {
+		return fmt.Errorf("invalid table encoding: %q", *encoding)
+	}
+
+	t, err := Solved(ctx)
+	if err != nil {
+		return err
+	}
+	if *maxTurn >= 0 {
+		t = t.Prune(func(s State, _ int8) bool { return s.Turn() > *maxTurn })
+	}
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if *encoding == "compact" {
+		err = t.WriteCompact(w)
+	} else {
+		_, err = t.WriteTo(w)
+	}
+	if err != nil {
+		return err
+	}
+	if f, ok := w.(*os.File); ok && f != os.Stdout {
+		return f.Close()
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package bsquare
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Settings holds flag defaults loaded from a configuration file. Keys
+// outside any table apply to every command with a flag of that name and
+// the same type of value, and keys inside a [command] table apply only
+// to that command:
+//
+//	theme = "ascii"
+//
+//	[serve]
+//	addr = ":9000"
+type Settings map[string]map[string]Setting
+
+// Setting is a configured value in its flag representation, with the
+// TOML type it was given as: "string", "integer", "float", or "boolean".
+type Setting struct {
+	Value string
+	Type  string
+}
+
+// fits indicates if a setting has the type of a flag's value. Integers
+// also fit float flags, and flags of other types, such as durations,
+// take strings.
+func (c Setting) fits(f *flag.Flag) bool {
+	want := "string"
+	if g, ok := f.Value.(flag.Getter); ok {
+		switch g.Get().(type) {
+		case bool:
+			want = "boolean"
+		case int, int64, uint, uint64:
+			want = "integer"
+		case float64:
+			want = "float"
+		}
+	}
+	return c.Type == want || c.Type == "integer" && want == "float"
+}
+
+// configPath returns the configuration file location, which may be
+// overridden by the BSQUARE_CONFIG environment variable.
+func configPath() (string, error) {
+	if path := os.Getenv("BSQUARE_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "bsquare", "config.toml"), nil
+}
+
+// LoadSettings reads the user's configuration file. A missing file is an
+// empty configuration.
+func LoadSettings() (Settings, error) {
+	path, err := configPath()
+	if err != nil {
+		return Settings{}, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Settings{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c, err := ParseSettings(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", path, err)
+	}
+	return c, nil
+}
+
+// ParseSettings parses the subset of TOML used for configuration: tables,
+// and keys with string, integer, float, or boolean values.
+func ParseSettings(r io.Reader) (Settings, error) {
+	c := Settings{"": {}}
+	table := ""
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("%d: invalid table header", n)
+			}
+			table = strings.TrimSpace(line[1 : len(line)-1])
+			if table == "" || c[table] != nil {
+				return nil, fmt.Errorf("%d: invalid table: %q", n, table)
+			}
+			c[table] = map[string]Setting{}
+			continue
+		}
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%d: expected key = value", n)
+		}
+		key = strings.TrimSpace(key)
+		value, err := configValue(strings.TrimSpace(raw))
+		if key == "" || err != nil {
+			return nil, fmt.Errorf("%d: invalid value for %q", n, key)
+		}
+		if _, dup := c[table][key]; dup {
+			return nil, fmt.Errorf("%d: duplicate key: %q", n, key)
+		}
+		c[table][key] = value
+	}
+	return c, scanner.Err()
+}
+
+// stripComment removes a trailing comment outside of string quotes.
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0 && r == quote && (quote == '\'' || line[i-1] != '\\'):
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// configValue converts a TOML value to its flag representation.
+func configValue(raw string) (Setting, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		s, err := strconv.Unquote(raw)
+		return Setting{s, "string"}, err
+	case strings.HasPrefix(raw, "'"):
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") {
+			return Setting{}, errors.New("unterminated string")
+		}
+		return Setting{raw[1 : len(raw)-1], "string"}, nil
+	case raw == "true" || raw == "false":
+		return Setting{raw, "boolean"}, nil
+	}
+	n := strings.ReplaceAll(raw, "_", "")
+	if _, err := strconv.ParseInt(n, 10, 64); err == nil {
+		return Setting{n, "integer"}, nil
+	}
+	if _, err := strconv.ParseFloat(n, 64); err != nil {
+		return Setting{}, err
+	}
+	return Setting{n, "float"}, nil
+}
+
+// config is the configuration loaded at startup.
+var config = Settings{}
+
+// tableCache returns the solved table file named by the top-level
+// table-cache key, or "" if there is none.
+func tableCache() string {
+	if c := config[""]["table-cache"]; c.Type == "string" {
+		return c.Value
+	}
+	return ""
+}
+
+// parseFlags parses a command's arguments after applying configured
+// defaults, so that flags given on the command line take precedence.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	for key, value := range config[""] {
+		if f := flags.Lookup(key); f != nil && value.fits(f) {
+			if err := flags.Set(key, value.Value); err != nil {
+				return fmt.Errorf("config: %s: %w", key, err)
+			}
+		}
+	}
+	for key, value := range config[flags.Name()] {
+		if flags.Lookup(key) == nil {
+			return fmt.Errorf("config: [%s] has no flag %q", flags.Name(), key)
+		}
+		if err := flags.Set(key, value.Value); err != nil {
+			return fmt.Errorf("config: [%s] %s: %w", flags.Name(), key, err)
+		}
+	}
+	return flags.Parse(args)
+}
@@ -0,0 +1,46 @@
+package bsquare
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+// TestParseFlags checks that top-level keys only set flags of the same
+// type, while command tables set theirs regardless.
+func TestParseFlags(t *testing.T) {
+	c, err := ParseSettings(strings.NewReader(`
+games = 3
+delay = "2s"
+ratio = 2
+
+[solve]
+interval = "5s"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(old Settings) { config = old }(config)
+	config = c
+
+	match := flag.NewFlagSet("match", flag.ContinueOnError)
+	games := match.Int("games", 100, "")
+	delay := match.Duration("delay", 0, "")
+	ratio := match.Float64("ratio", 1, "")
+	if err := parseFlags(match, nil); err != nil {
+		t.Fatal(err)
+	}
+	if *games != 3 || delay.String() != "2s" || *ratio != 2 {
+		t.Errorf("match: got games %d, delay %v, ratio %v", *games, *delay, *ratio)
+	}
+
+	solve := flag.NewFlagSet("solve", flag.ContinueOnError)
+	count := solve.Bool("games", false, "")
+	interval := solve.Duration("interval", 0, "")
+	if err := parseFlags(solve, nil); err != nil {
+		t.Fatal(err)
+	}
+	if *count || interval.String() != "5s" {
+		t.Errorf("solve: got games %v, interval %v", *count, *interval)
+	}
+}
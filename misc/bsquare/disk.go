@@ -0,0 +1,316 @@
+package bsquare
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+)
+
+// TableStore is a table of solved positions keyed by canonical state.
+type TableStore interface {
+	Get(s State) (int8, bool)
+	Put(s State, score int8)
+}
+
+// Get implements TableStore.
+func (t Minimax) Get(s State) (int8, bool) {
+	score, ok := t[s]
+	return score, ok
+}
+
+// Put implements TableStore.
+func (t Minimax) Put(s State, score int8) {
+	t[s] = score
+}
+
+// SolveStore evaluates a game state like Minimax.EvaluateContext, but
+// records results in any table store.
+func SolveStore(ctx context.Context, t TableStore, s State, m Mask) (int, error) {
+	return solveStore(ctx, t, new(SearchStats), s, m)
+}
+
+// SolveStoreStats is SolveStore, also reporting the search effort, with
+// positions already in the store counted as hits. The table size is the
+// store's length, if it reports one.
+func SolveStoreStats(ctx context.Context, t TableStore, s State, m Mask) (int, SearchStats, error) {
+	var score int
+	var err error
+	st := measure(s, func(st *SearchStats) { score, err = solveStore(ctx, t, st, s, m) })
+	st.TableSize = storeLen(t)
+	return score, st, err
+}
+
+// storeLen returns the number of entries in a table store, or zero if it
+// does not report one.
+func storeLen(t TableStore) int {
+	switch t := t.(type) {
+	case Minimax:
+		return len(t)
+	case interface{ Len() int }:
+		return t.Len()
+	}
+	return 0
+}
+
+func solveStore(ctx context.Context, t TableStore, st *SearchStats, s State, m Mask) (int, error) {
+	st.Nodes++
+	st.MaxDepth = max(st.MaxDepth, s.Turn())
+	s0 := s.Canonicalize()
+	if score, ok := t.Get(s0); ok {
+		st.Hits++
+		return int(score), nil
+	}
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	var score int
+	if s.IsComplete(m) {
+		score = s0.Score()
+	} else if s.NoMoves(m) {
+		var err error
+		if score, err = solveStore(ctx, t, st, s.Pass(), m.Pass()); err != nil {
+			return 0, err
+		}
+	} else {
+		score = s.InitScore()
+		for b := m.LegalBits(m.Turn()); b != 0; b &= b - 1 {
+			i := bits.TrailingZeros32(b)
+			v, err := solveStore(ctx, t, st, s.Place(i), m.Place(i))
+			if err != nil {
+				return 0, err
+			}
+			if s.ToMove() == SecondPlayer {
+				score = min(score, v)
+			} else {
+				score = max(score, v)
+			}
+		}
+	}
+	t.Put(s0, int8(score))
+	return score, nil
+}
+
+// DiskTable is a TableStore in a file: an open-addressed hash table of
+// fixed capacity, read and written through a bounded cache of pages, so
+// that memory use is independent of the table size. The file begins
+// with a one-page header, and each 8-byte slot packs a state, a used
+// bit, and a 7-bit score:
+//
+//	bits 0-55   state
+//	bit  56     used
+//	bits 57-63  score, two's complement
+//
+// I/O errors, and putting into a full table, are recorded and returned
+// by Close.
+type DiskTable struct {
+	f        *os.File
+	capacity uint64 // slots, a power of two
+	count    uint64
+	err      error
+
+	pages    map[uint64]*diskPage
+	ring     []*diskPage // clock replacement
+	hand     int
+	maxPages int
+}
+
+type diskPage struct {
+	index uint64
+	data  [diskPageSize]byte
+	dirty bool
+	ref   bool
+}
+
+const (
+	diskPageSize  = 4096
+	diskPageSlots = diskPageSize / 8
+	diskMagic     = "BSQD"
+)
+
+var errDiskFull = errors.New("disk table full")
+
+// OpenDiskTable opens a disk table, creating it with room for at least
+// capacity entries if it does not exist. At most cache bytes of pages
+// are held in memory. An existing table keeps its entries, so a solve
+// into it resumes where it stopped.
+func OpenDiskTable(path string, capacity, cache int) (*DiskTable, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	t := &DiskTable{
+		f:        f,
+		pages:    make(map[uint64]*diskPage),
+		maxPages: max(cache/diskPageSize, 16),
+	}
+
+	var header [diskPageSize]byte
+	n, err := f.ReadAt(header[:], 0)
+	switch {
+	case n == 0 && err == io.EOF:
+		// Keep the load factor at most 3/4
+		t.capacity = 1 << bits.Len64(uint64(capacity)*4/3)
+		t.capacity = max(t.capacity, diskPageSlots)
+		err = f.Truncate(int64(diskPageSize + 8*t.capacity))
+	case err != nil:
+	case string(header[:4]) != diskMagic:
+		err = errors.New("not a disk table")
+	default:
+		t.capacity = binary.LittleEndian.Uint64(header[8:])
+		t.count = binary.LittleEndian.Uint64(header[16:])
+		if t.capacity < diskPageSlots || t.capacity&(t.capacity-1) != 0 {
+			err = fmt.Errorf("invalid capacity: %d", t.capacity)
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Len returns the number of entries.
+func (t *DiskTable) Len() int {
+	return int(t.count)
+}
+
+// slot returns the page holding a slot and the slot's offset in it.
+func (t *DiskTable) slot(i uint64) (*diskPage, int) {
+	index := i / diskPageSlots
+	p := t.pages[index]
+	if p == nil {
+		p = t.load(index)
+	}
+	p.ref = true
+	return p, int(i%diskPageSlots) * 8
+}
+
+// load reads a page into the cache, evicting another if it is full.
+func (t *DiskTable) load(index uint64) *diskPage {
+	var p *diskPage
+	if len(t.ring) < t.maxPages {
+		p = new(diskPage)
+		t.ring = append(t.ring, p)
+	} else {
+		for {
+			p = t.ring[t.hand]
+			t.hand = (t.hand + 1) % len(t.ring)
+			if !p.ref {
+				break
+			}
+			p.ref = false
+		}
+		t.write(p)
+		delete(t.pages, p.index)
+	}
+	p.index = index
+	p.dirty = false
+	if _, err := t.f.ReadAt(p.data[:], t.offset(index)); err != nil && t.err == nil {
+		t.err = err
+	}
+	t.pages[index] = p
+	return p
+}
+
+func (t *DiskTable) offset(index uint64) int64 {
+	return int64(diskPageSize + index*diskPageSize)
+}
+
+// write writes a dirty page back to the file.
+func (t *DiskTable) write(p *diskPage) {
+	if !p.dirty {
+		return
+	}
+	if _, err := t.f.WriteAt(p.data[:], t.offset(p.index)); err != nil && t.err == nil {
+		t.err = err
+	}
+	p.dirty = false
+}
+
+// probe finds the slot for a state: the slot holding it, or the empty
+// slot where it belongs. The page is nil when the table is full.
+func (t *DiskTable) probe(s State) (*diskPage, int, uint64) {
+	mask := t.capacity - 1
+	i := uint64(s) * 0x9e3779b97f4a7c15 >> (64 - bits.Len64(mask))
+	for n := uint64(0); n < t.capacity; n++ {
+		p, off := t.slot(i)
+		v := binary.LittleEndian.Uint64(p.data[off:])
+		if v>>56&1 == 0 || State(v&0xffffffffffffff) == s {
+			return p, off, v
+		}
+		i = (i + 1) & mask
+	}
+	return nil, 0, 0
+}
+
+// Get implements TableStore.
+func (t *DiskTable) Get(s State) (int8, bool) {
+	p, _, v := t.probe(s)
+	if p == nil || v>>56&1 == 0 {
+		return 0, false
+	}
+	return int8(v>>56) >> 1, true
+}
+
+// Put implements TableStore.
+func (t *DiskTable) Put(s State, score int8) {
+	p, off, v := t.probe(s)
+	if p == nil {
+		if t.err == nil {
+			t.err = errDiskFull
+		}
+		return
+	}
+	if v>>56&1 == 0 {
+		t.count++
+	}
+	v = uint64(s) | 1<<56 | uint64(uint8(score))<<57
+	binary.LittleEndian.PutUint64(p.data[off:], v)
+	p.dirty = true
+}
+
+// Range calls fn on every entry, in file order.
+func (t *DiskTable) Range(fn func(s State, score int8)) error {
+	for index := uint64(0); index < t.capacity/diskPageSlots; index++ {
+		p, _ := t.slot(index * diskPageSlots)
+		for off := 0; off < diskPageSize; off += 8 {
+			v := binary.LittleEndian.Uint64(p.data[off:])
+			if v>>56&1 == 1 {
+				fn(State(v&0xffffffffffffff), int8(v>>56)>>1)
+			}
+		}
+	}
+	return t.err
+}
+
+// Flush writes cached pages and the header to the file.
+func (t *DiskTable) Flush() error {
+	for _, p := range t.ring {
+		t.write(p)
+	}
+	var header [24]byte
+	copy(header[:], diskMagic)
+	binary.LittleEndian.PutUint64(header[8:], t.capacity)
+	binary.LittleEndian.PutUint64(header[16:], t.count)
+	if _, err := t.f.WriteAt(header[:], 0); err != nil && t.err == nil {
+		t.err = err
+	}
+	return t.err
+}
+
+// Close flushes and closes the table, returning the first error
+// encountered since it was opened.
+func (t *DiskTable) Close() error {
+	err := t.Flush()
+	if cerr := t.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,211 @@
+package bsquare
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const editHelp = `Commands:
+  x N...     place first player pieces on squares N (1-25)
+  o N...     place second player pieces
+  - N...     clear squares
+  move P     set the player to move (1 or 2)
+  turn N     set the 0-indexed turn count
+  reset      clear the board
+  analyze    analyze the position
+  play [P]   play from the position, the engine taking player P (0: none)
+  quit       leave the editor`
+
+// Editor builds an arbitrary position.
+type Editor struct {
+	State State
+}
+
+// Set places a player's piece on a square, replacing any piece there. The
+// turn count is raised if needed to account for the pieces.
+func (e *Editor) Set(i int, who Player) {
+	e.Clear(i)
+	e.State |= 1 << (who.offset() + i)
+	e.fitTurn(e.State.ToMove())
+}
+
+// Clear removes any piece from a square.
+func (e *Editor) Clear(i int) {
+	e.State &^= 1<<i | 1<<(i+25)
+}
+
+// SetTurn sets the turn count.
+func (e *Editor) SetTurn(turn int) error {
+	if turn < 0 || turn > 63 {
+		return fmt.Errorf("invalid turn: %d", turn)
+	}
+	e.State = e.State&0x3ffffffffffff | State(turn)<<50
+	return nil
+}
+
+// SetToMove sets the player to move, choosing the smallest turn count
+// holding the pieces on the board.
+func (e *Editor) SetToMove(who Player) {
+	e.State &= 0x3ffffffffffff
+	e.fitTurn(who)
+}
+
+// fitTurn raises the turn count, if needed, to the smallest holding the
+// pieces on the board with the given player to move.
+func (e *Editor) fitTurn(who Player) {
+	s := e.State
+	turn := s.Turn()
+	for turn < 63 && (TurnPlayer(turn) != who ||
+		s.Pieces(FirstPlayer) > (turn+1)/2 || s.Pieces(SecondPlayer) > turn/2) {
+		turn++
+	}
+	e.SetTurn(turn)
+}
+
+// squares parses 1-indexed square arguments.
+func squares(args []string) ([]int, error) {
+	if len(args) == 0 {
+		return nil, errors.New("no squares given")
+	}
+	var is []int
+	for _, arg := range args {
+		i, err := strconv.Atoi(arg)
+		if err != nil || i < 1 || i > 25 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSquare, arg)
+		}
+		is = append(is, i-1)
+	}
+	return is, nil
+}
+
+// editMain implements the "edit" command, a position editor reading one
+// command per line. A cursor-driven full-screen editor is deferred until
+// the terminal can be put in raw mode without outside packages.
+func editMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("edit", flag.ContinueOnError)
+	in := addInput(flags)
+	display := addTheme(flags)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if err := display.apply(); err != nil {
+		return err
+	}
+	s, _, err := in.position(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	e := &Editor{State: s}
+	var t Minimax
+	stdin := bufio.NewScanner(os.Stdin)
+	fmt.Println(`(Type "help" for commands.)`)
+	for {
+		fmt.Printf("%s  player %v to move\n", e.State, e.State.ToMove())
+		if err := e.State.Validate(); err != nil {
+			fmt.Println("Invalid:", err)
+		}
+		theme.PrintBoard(os.Stdout, e.State, e.State.DeriveLoose(), -1)
+
+		fmt.Print("edit> ")
+		if !stdin.Scan() {
+			fmt.Println()
+			return stdin.Err()
+		}
+		fields := strings.Fields(stdin.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		err := e.command(fields)
+		if errors.Is(err, errEditDone) {
+			return nil
+		} else if errors.Is(err, errEditHandoff) {
+			if err := e.State.Validate(); err != nil {
+				fmt.Println("Invalid:", err)
+				continue
+			}
+			if t == nil {
+				if t, err = Solved(ctx); err != nil {
+					return err
+				}
+			}
+			m := e.State.DeriveLoose()
+			if fields[0] == "analyze" {
+				analyze(os.Stdout, t, e.State, m)
+				continue
+			}
+			side := 0
+			if len(fields) > 1 {
+				side, err = strconv.Atoi(fields[1])
+			} else {
+				side = int(e.State.ToMove().Other()) + 1
+			}
+			if err != nil || side < 0 || side > 2 {
+				fmt.Println("Invalid side")
+				continue
+			}
+			if err := play(stdin, t, Perfect{Table: t}, side, false, e.State, m); err != nil {
+				return err
+			}
+		} else if err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+var (
+	errEditDone    = errors.New("done")
+	errEditHandoff = errors.New("handoff")
+)
+
+// command applies one editor command.
+func (e *Editor) command(fields []string) error {
+	switch fields[0] {
+	case "help":
+		fmt.Println(editHelp)
+	case "x", "o", "-":
+		is, err := squares(fields[1:])
+		if err != nil {
+			return err
+		}
+		for _, i := range is {
+			switch fields[0] {
+			case "x":
+				e.Set(i, FirstPlayer)
+			case "o":
+				e.Set(i, SecondPlayer)
+			default:
+				e.Clear(i)
+			}
+		}
+	case "move":
+		if len(fields) != 2 || (fields[1] != "1" && fields[1] != "2") {
+			return errors.New("usage: move 1|2")
+		}
+		e.SetToMove(Player(fields[1][0] - '1'))
+	case "turn":
+		if len(fields) != 2 {
+			return errors.New("usage: turn N")
+		}
+		turn, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return fmt.Errorf("invalid turn: %q", fields[1])
+		}
+		return e.SetTurn(turn)
+	case "reset":
+		e.State = 0
+	case "analyze", "play":
+		return errEditHandoff
+	case "quit":
+		return errEditDone
+	default:
+		return fmt.Errorf("unknown command: %q", fields[0])
+	}
+	return nil
+}
@@ -0,0 +1,662 @@
+package bsquare
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"math/bits"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Engine chooses moves: a square index, or -1 to pass.
+type Engine interface {
+	Move(s State, m Mask) int
+}
+
+// ContextEngine is an engine that can stop early, playing the best move
+// found before the context is done.
+type ContextEngine interface {
+	Engine
+	MoveContext(ctx context.Context, s State, m Mask) int
+}
+
+var (
+	randMu  sync.Mutex
+	randSrc rand.Source = rand.NewSource(time.Now().UnixNano())
+)
+
+// SetRandSource sets the source of randomness shared by all stochastic
+// engines that were not given their own generator. Setting a seeded
+// source makes runs reproducible.
+func SetRandSource(src rand.Source) {
+	randMu.Lock()
+	defer randMu.Unlock()
+	randSrc = src
+}
+
+// lockedSource guards the shared source for concurrent engines.
+type lockedSource struct{}
+
+func (lockedSource) Int63() int64 {
+	randMu.Lock()
+	defer randMu.Unlock()
+	return randSrc.Int63()
+}
+
+func (lockedSource) Seed(seed int64) {
+	randMu.Lock()
+	defer randMu.Unlock()
+	randSrc.Seed(seed)
+}
+
+// engineRand returns r, or a generator drawing from the shared source.
+func engineRand(r *rand.Rand) *rand.Rand {
+	if r != nil {
+		return r
+	}
+	return rand.New(lockedSource{})
+}
+
+// legal returns the legal moves for the player to move.
+func legal(m Mask) []int {
+	var moves []int
+	for b := m.LegalBits(m.Turn()); b != 0; b &= b - 1 {
+		moves = append(moves, bits.TrailingZeros32(b))
+	}
+	return moves
+}
+
+// Perfect always plays a perfect move, choosing among equally scored
+// moves by Tiebreak, or the first when nil.
+type Perfect struct {
+	Table    Minimax
+	Tiebreak Tiebreak
+}
+
+// Move plays a perfect move.
+func (e Perfect) Move(s State, m Mask) int {
+	if s.NoMoves(m) {
+		return -1
+	}
+	moves := e.Table.Suggest(s, m)
+	if e.Tiebreak == nil || len(moves) == 1 {
+		return moves[0]
+	}
+	return e.Tiebreak.Break(Variant{Table: e.Table}, s, m, false, moves)
+}
+
+// Tiebreak chooses among equally scored moves under a variant's rules,
+// given in square order. Under voluntary passes a pass that scores the
+// same follows them as -1, and passed reports if the last move passed,
+// so that passing again ends the game. Under forced passes a pass is
+// never among the moves.
+type Tiebreak interface {
+	Break(v Variant, s State, m Mask, passed bool, moves []int) int
+}
+
+// TieFirst breaks ties by the lowest square, so places over passing.
+type TieFirst struct{}
+
+// Break chooses the first move.
+func (TieFirst) Break(v Variant, s State, m Mask, passed bool, moves []int) int {
+	return moves[0]
+}
+
+// TieRandom breaks ties uniformly at random among placements, passing
+// only when no placement ties. A nil Rand uses the shared source.
+type TieRandom struct {
+	Rand *rand.Rand
+}
+
+// Break chooses a random move.
+func (b TieRandom) Break(v Variant, s State, m Mask, passed bool, moves []int) int {
+	if n := len(moves); n > 1 && moves[n-1] < 0 {
+		moves = moves[:n-1]
+	}
+	return moves[engineRand(b.Rand).Intn(len(moves))]
+}
+
+// TieMaterial breaks ties by the most pieces the player to move ends
+// with, supposing both players keep playing perfectly and breaking ties
+// the same way, and then by the lowest square, placing over passing. It
+// prefers winning 12 to 10 over 10 to 8, for play that looks natural.
+// Final piece counts are memoized by canonical state, for the rules of
+// the last variant given.
+type TieMaterial struct {
+	mu    sync.Mutex
+	rules Rules
+	memo  map[State][2]int8
+}
+
+// Break chooses the move keeping the most material.
+func (b *TieMaterial) Break(v Variant, s State, m Mask, passed bool, moves []int) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.memo == nil || b.rules != v.Rules {
+		b.rules, b.memo = v.Rules, make(map[State][2]int8)
+	}
+	i, _ := b.choose(v, s, m, passed, moves)
+	return i
+}
+
+// choose returns the best move and its final piece counts.
+func (b *TieMaterial) choose(v Variant, s State, m Mask, passed bool, moves []int) (int, [2]int8) {
+	who := s.ToMove()
+	best, bestPieces := 0, [2]int8{-1, -1}
+	for _, i := range moves {
+		var pieces [2]int8
+		switch {
+		case i >= 0:
+			pieces = b.pieces(v, s.Place(i), m.Place(i), false)
+		case passed && v.Rules.VoluntaryPass:
+			pieces = finalPieces(s) // the position repeats
+		default:
+			pieces = b.pieces(v, s.Pass(), m.Pass(), true)
+		}
+		if pieces[who] > bestPieces[who] {
+			best, bestPieces = i, pieces
+		}
+	}
+	return best, bestPieces
+}
+
+// pieces returns the final piece counts from a game state.
+func (b *TieMaterial) pieces(v Variant, s State, m Mask, passed bool) [2]int8 {
+	c := s.Canonicalize()
+	if passed && v.Rules.VoluntaryPass {
+		c |= 1 << 63 // a second pass would end the game
+	}
+	if p, ok := b.memo[c]; ok {
+		return p
+	}
+	var p [2]int8
+	if v.Rules.IsComplete(s, m) {
+		p = finalPieces(s)
+	} else {
+		_, p = b.choose(v, s, m, passed, v.perfect(s, m, passed))
+	}
+	b.memo[c] = p
+	return p
+}
+
+// finalPieces returns both players' piece counts.
+func finalPieces(s State) [2]int8 {
+	return [2]int8{int8(s.Pieces(FirstPlayer)), int8(s.Pieces(SecondPlayer))}
+}
+
+// tiebreaks names the tie-breaking policies.
+var tiebreaks = []string{"first", "random", "material"}
+
+// newTiebreak returns the tie-breaking policy with the given name.
+func newTiebreak(name string) (Tiebreak, error) {
+	switch name {
+	case "first":
+		return TieFirst{}, nil
+	case "random":
+		return TieRandom{}, nil
+	case "material":
+		return new(TieMaterial), nil
+	}
+	return nil, fmt.Errorf("unknown tiebreak: %s", name)
+}
+
+// Varied plays perfectly, choosing uniformly at random among the classes
+// of perfect moves equivalent by symmetry, then among the moves of the
+// chosen class, so that repeated games vary without favoring moves that
+// merely have more symmetric twins. A nil Rand uses the shared source.
+type Varied struct {
+	Table Minimax
+	Rand  *rand.Rand
+}
+
+// Move plays a random perfect move.
+func (e Varied) Move(s State, m Mask) int {
+	if s.NoMoves(m) {
+		return -1
+	}
+	var classes [][]int
+	index := make(map[State]int)
+	for _, i := range e.Table.Suggest(s, m) {
+		c := s.Place(i).Canonicalize()
+		k, ok := index[c]
+		if !ok {
+			k = len(classes)
+			index[c] = k
+			classes = append(classes, nil)
+		}
+		classes[k] = append(classes[k], i)
+	}
+	r := engineRand(e.Rand)
+	class := classes[r.Intn(len(classes))]
+	return class[r.Intn(len(class))]
+}
+
+// Random plays uniformly random legal moves. A nil Rand uses the shared
+// source.
+type Random struct {
+	Rand *rand.Rand
+}
+
+// Move plays a random legal move.
+func (e Random) Move(s State, m Mask) int {
+	if s.NoMoves(m) {
+		return -1
+	}
+	moves := legal(m)
+	return moves[engineRand(e.Rand).Intn(len(moves))]
+}
+
+// Epsilon plays perfectly except with probability Epsilon, when it plays
+// a random legal move instead. Ties between perfect moves are broken by
+// Tiebreak, as with Perfect. A nil Rand uses the shared source.
+type Epsilon struct {
+	Table    Minimax
+	Epsilon  float64
+	Rand     *rand.Rand
+	Tiebreak Tiebreak
+}
+
+// Move plays a perfect move, or occasionally a random move.
+func (e Epsilon) Move(s State, m Mask) int {
+	r := engineRand(e.Rand)
+	if r.Float64() < e.Epsilon {
+		return Random{r}.Move(s, m)
+	}
+	return Perfect{e.Table, e.Tiebreak}.Move(s, m)
+}
+
+// Shallow searches a fixed number of turns ahead, scoring the positions
+// at the horizon by the piece difference plus half the difference in
+// squares still open to each player. Ties between equally scored moves
+// are broken at random. A nil Rand uses the shared source.
+type Shallow struct {
+	Depth int
+	Rand  *rand.Rand
+}
+
+// Move plays the best move found by the limited search.
+func (e Shallow) Move(s State, m Mask) int {
+	i, _ := e.MoveStats(s, m)
+	return i
+}
+
+// MoveStats is Move, also reporting the search effort.
+func (e Shallow) MoveStats(s State, m Mask) (int, SearchStats) {
+	if s.NoMoves(m) {
+		return -1, SearchStats{}
+	}
+	var i int
+	st := measure(s, func(st *SearchStats) {
+		i, _ = shallowMove(context.Background(), st, s, m, e.Depth, engineRand(e.Rand))
+	})
+	return i, st
+}
+
+// shallowMove picks a best move by a search depth turns ahead, counting
+// its effort into st, or stops early with the context's error if it is
+// cancelled.
+func shallowMove(ctx context.Context, st *SearchStats, s State, m Mask, depth int, r *rand.Rand) (int, error) {
+	var best []int
+	var bestScore int
+	for _, i := range legal(m) {
+		score := shallow(ctx, st, s.Place(i), m.Place(i), depth-1, -1<<30, 1<<30)
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		score *= s.ToMove().Sign()
+		if best == nil || score > bestScore {
+			best, bestScore = append(best[:0], i), score
+		} else if score == bestScore {
+			best = append(best, i)
+		}
+	}
+	return best[r.Intn(len(best))], nil
+}
+
+// shallow is a depth-limited alpha-beta search, scaled by 2 to keep the
+// heuristic integral, from the first player's perspective. Its result is
+// meaningless once the context is cancelled.
+func shallow(ctx context.Context, st *SearchStats, s State, m Mask, depth, alpha, beta int) int {
+	st.Nodes++
+	st.MaxDepth = max(st.MaxDepth, s.Turn())
+	if s.IsComplete(m) {
+		return 2 * s.Score()
+	}
+	if depth <= 0 {
+		// turns 2 and 1 select the first and second players' squares
+		open := bits.OnesCount32(m.LegalBits(2)) - bits.OnesCount32(m.LegalBits(1))
+		return 2*s.Score() + open
+	}
+	if ctx.Err() != nil {
+		return 0
+	}
+	if s.NoMoves(m) {
+		return shallow(ctx, st, s.Pass(), m.Pass(), depth, alpha, beta)
+	}
+	if s.ToMove() == FirstPlayer {
+		v := -1 << 30
+		for _, i := range legal(m) {
+			v = max(v, shallow(ctx, st, s.Place(i), m.Place(i), depth-1, alpha, beta))
+			if alpha = max(alpha, v); alpha >= beta {
+				break
+			}
+		}
+		return v
+	}
+	v := 1 << 30
+	for _, i := range legal(m) {
+		v = min(v, shallow(ctx, st, s.Place(i), m.Place(i), depth-1, alpha, beta))
+		if beta = min(beta, v); alpha >= beta {
+			break
+		}
+	}
+	return v
+}
+
+// Deepening searches like Shallow, one turn deeper at a time until the
+// Budget runs out, and plays the best move of the deepest search that
+// finished. It needs no table. A nil Rand uses the shared source.
+type Deepening struct {
+	Budget time.Duration
+	Rand   *rand.Rand
+}
+
+// Move plays the best move found within the budget.
+func (e Deepening) Move(s State, m Mask) int {
+	ctx, cancel := context.WithTimeout(context.Background(), e.Budget)
+	defer cancel()
+	return e.MoveContext(ctx, s, m)
+}
+
+// MoveStats is Move, also reporting the search effort summed over every
+// depth, including the unfinished last search.
+func (e Deepening) MoveStats(s State, m Mask) (int, SearchStats) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.Budget)
+	defer cancel()
+	var i int
+	st := measure(s, func(st *SearchStats) { i = e.moveContext(ctx, st, s, m) })
+	return i, st
+}
+
+// MoveContext plays the best move found before the context is done, or
+// the first legal move if not even a one-turn search finished.
+func (e Deepening) MoveContext(ctx context.Context, s State, m Mask) int {
+	return e.moveContext(ctx, new(SearchStats), s, m)
+}
+
+func (e Deepening) moveContext(ctx context.Context, st *SearchStats, s State, m Mask) int {
+	if s.NoMoves(m) {
+		return -1
+	}
+	r := engineRand(e.Rand)
+	move := legal(m)[0]
+	for depth := 1; depth <= 50-s.Turn(); depth++ {
+		i, err := shallowMove(ctx, st, s, m, depth, r)
+		if err != nil {
+			break
+		}
+		move = i
+	}
+	return move
+}
+
+// Levels maps difficulty levels 1 (easiest) to 10 (perfect) to engines,
+// ordered by their score deficit against perfect play, summed over both
+// seats and averaged over games. Measured deficits run from about 8.5
+// down through 5.3, 3.3, 2.6, 1.7, 1.5, 1.1, 0.6, and 0.2 to none, as
+// checked by TestLevels.
+func Levels(t Minimax) []Engine {
+	return []Engine{
+		Random{},
+		Epsilon{Table: t, Epsilon: 0.6},
+		Epsilon{Table: t, Epsilon: 0.4},
+		Shallow{Depth: 1},
+		Shallow{Depth: 2},
+		Shallow{Depth: 3},
+		Epsilon{Table: t, Epsilon: 0.15},
+		Epsilon{Table: t, Epsilon: 0.1},
+		Epsilon{Table: t, Epsilon: 0.03},
+		Varied{Table: t},
+	}
+}
+
+// PlayGame plays a full game between two engines, returning the final
+// state.
+func PlayGame(p1, p2 Engine) State {
+	s, _ := PlayMoves(p1, p2).Position()
+	return s
+}
+
+// PlayMoves plays a full game between two engines, returning its moves.
+func PlayMoves(p1, p2 Engine) *Game {
+	var s State
+	var m Mask
+	g := new(Game)
+	engines := [2]Engine{p1, p2}
+	for !s.IsComplete(m) {
+		i := engines[s.ToMove()].Move(s, m)
+		g.Moves = append(g.Moves, i)
+		s, m = child(s, m, i)
+	}
+	return g
+}
+
+// writeRecord writes a record to a file in game file format.
+func writeRecord(path string, rec *Record) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := rec.WriteGame(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// EngineOptions are the engine settings shared by commands, which the
+// engine factories read.
+type EngineOptions struct {
+	Epsilon  float64
+	Tiebreak string
+}
+
+// addEngineOptions registers the engine settings on a command's flag set.
+func addEngineOptions(flags *flag.FlagSet) *EngineOptions {
+	opts := new(EngineOptions)
+	flags.Float64Var(&opts.Epsilon, "epsilon", 0.1, "random move rate for epsilon")
+	flags.StringVar(&opts.Tiebreak, "tiebreak", "first",
+		"choice among equal perfect moves for perfect and epsilon ("+strings.Join(tiebreaks, ", ")+")")
+	return opts
+}
+
+// seedFlag is the -seed flag of the commands playing stochastic engines.
+type seedFlag struct {
+	seed *int64
+}
+
+// addSeed registers the -seed flag on a command's flag set.
+func addSeed(flags *flag.FlagSet) seedFlag {
+	return seedFlag{flags.Int64("seed", 0, "random seed (0: from the clock, printed to stderr)")}
+}
+
+// apply seeds the shared source of randomness. A seed taken from the
+// clock is printed to standard error so that the run can be repeated.
+func (f seedFlag) apply() {
+	seed := *f.seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+		fmt.Fprintln(os.Stderr, "seed:", seed)
+	}
+	SetRandSource(rand.NewSource(seed))
+}
+
+// EngineFactory makes an engine from the solved table and the shared
+// engine settings.
+type EngineFactory func(t Minimax, opts *EngineOptions) (Engine, error)
+
+// engineFactories holds the registered engines by name.
+var engineFactories = make(map[string]EngineFactory)
+
+// RegisterEngine makes an engine selectable by name with NewEngine, and
+// so wherever commands take one, such as play, match, arena, and serve.
+// Other packages, and files of this one behind build tags, register from
+// init. It panics if the name is taken.
+func RegisterEngine(name string, factory EngineFactory) {
+	if _, ok := engineFactories[name]; ok {
+		panic("engine registered twice: " + name)
+	}
+	engineFactories[name] = factory
+}
+
+func init() {
+	RegisterEngine("perfect", func(t Minimax, opts *EngineOptions) (Engine, error) {
+		tiebreak, err := newTiebreak(opts.Tiebreak)
+		return Perfect{t, tiebreak}, err
+	})
+	RegisterEngine("varied", func(t Minimax, opts *EngineOptions) (Engine, error) {
+		return Varied{Table: t}, nil
+	})
+	RegisterEngine("random", func(t Minimax, opts *EngineOptions) (Engine, error) {
+		return Random{}, nil
+	})
+	RegisterEngine("epsilon", func(t Minimax, opts *EngineOptions) (Engine, error) {
+		tiebreak, err := newTiebreak(opts.Tiebreak)
+		return Epsilon{Table: t, Epsilon: opts.Epsilon, Tiebreak: tiebreak}, err
+	})
+	RegisterEngine("deepening", func(t Minimax, opts *EngineOptions) (Engine, error) {
+		return Deepening{Budget: time.Second}, nil
+	})
+	RegisterEngine("expectimax", func(t Minimax, opts *EngineOptions) (Engine, error) {
+		return NewExpectimax(t, 0), nil
+	})
+}
+
+// Engines returns the registered engine names in order.
+func Engines() []string {
+	names := make([]string, 0, len(engineFactories))
+	for name := range engineFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// engineUsage lists the engine names for flag usage, registered names
+// first, then the parameterized built-ins.
+func engineUsage() string {
+	return strings.Join(append(Engines(), "expectimax:BETA", "level1-level10"), ", ")
+}
+
+// NewEngine returns the engine with the given name: a registered name,
+// expectimax:BETA, or a difficulty level. Nil options are the command
+// defaults.
+func NewEngine(name string, t Minimax, opts *EngineOptions) (Engine, error) {
+	if opts == nil {
+		opts = addEngineOptions(flag.NewFlagSet("", flag.ContinueOnError))
+	}
+	if factory, ok := engineFactories[name]; ok {
+		return factory(t, opts)
+	}
+	if beta, ok := strings.CutPrefix(name, "expectimax:"); ok {
+		b, err := strconv.ParseFloat(beta, 64)
+		if err != nil || !(b >= 0 && b < math.Inf(1)) {
+			return nil, fmt.Errorf("invalid softmax beta: %q", beta)
+		}
+		return NewExpectimax(t, b), nil
+	}
+	if level, ok := strings.CutPrefix(name, "level"); ok {
+		n, err := strconv.Atoi(level)
+		if err != nil || n < 1 || n > 10 {
+			return nil, fmt.Errorf("invalid level: %q", level)
+		}
+		return Levels(t)[n-1], nil
+	}
+	return nil, fmt.Errorf("unknown engine: %s", name)
+}
+
+// matchMain implements the "match" command: match [FLAGS] ENGINE ENGINE
+func matchMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("match", flag.ContinueOnError)
+	seed := addSeed(flags)
+	games := flags.Int("games", 100, "number of games")
+	opts := addEngineOptions(flags)
+	archive := flags.String("archive", "", "write each game with headers to this directory")
+	event := flags.String("event", "match", "Event header for archived games")
+	format := addFormat(flags, "text")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return errors.New("usage: match [flags] ENGINE ENGINE")
+	}
+	if *archive != "" {
+		if err := os.MkdirAll(*archive, 0o755); err != nil {
+			return err
+		}
+	}
+	seed.apply()
+
+	t, err := Solved(ctx)
+	if err != nil {
+		return err
+	}
+	var engines [2]*Tally
+	for i, name := range flags.Args() {
+		e, err := NewEngine(name, t, opts)
+		if err != nil {
+			return err
+		}
+		engines[i] = &Tally{Engine: e}
+	}
+
+	var p1, p2, ties, total int
+	for i := 0; i < *games; i++ {
+		g := PlayMoves(engines[0], engines[1])
+		s, _ := g.Position()
+		score := s.Score()
+		if *archive != "" {
+			rec := NewRecord(g)
+			rec.Headers = Headers{
+				Event:   *event,
+				Date:    time.Now().Format(time.DateOnly),
+				Players: [2]string{flags.Arg(0), flags.Arg(1)},
+				Rules:   Rules{}.String(),
+				Result:  g.Result(),
+			}
+			path := filepath.Join(*archive, fmt.Sprintf("%04d.game", i+1))
+			if err := writeRecord(path, rec); err != nil {
+				return err
+			}
+		}
+		total += score
+		if score > 0 {
+			p1++
+		} else if score < 0 {
+			p2++
+		} else {
+			ties++
+		}
+	}
+	r := new(Report)
+	r.Add("Player 1 wins", p1)
+	r.Add("Player 2 wins", p2)
+	r.Add("Ties", ties)
+	avg := float64(total) / float64(*games)
+	r.AddText("Average score", avg, fmt.Sprintf("%+.3f", avg))
+	effort := r.Table("Effort", "engine", "moves", "nodes", "hits", "max depth", "time", "table size")
+	for i, e := range engines {
+		st := e.Stats
+		effort.Add(flags.Arg(i), e.Moves, st.Nodes, st.Hits, st.MaxDepth, st.Duration, st.TableSize)
+	}
+	return format.Write(os.Stdout, r)
+}
@@ -0,0 +1,39 @@
+package bsquare
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestLevels checks that each difficulty level loses less to perfect
+// play than the level below it, by the score deficit summed over both
+// seats.
+func TestLevels(t *testing.T) {
+	if testing.Short() {
+		t.Skip("solves the full game")
+	}
+	table := New()
+	value := table.Evaluate(0, 0)
+	defer SetRandSource(rand.NewSource(rand.Int63()))
+	SetRandSource(rand.NewSource(1))
+
+	const games = 1000
+	perfect := Perfect{Table: table}
+	last := 0.0
+	for n, e := range Levels(table) {
+		deficit := 0
+		for i := 0; i < games; i++ {
+			deficit += value - PlayGame(e, perfect).Score()
+			deficit += PlayGame(perfect, e).Score() - value
+		}
+		d := float64(deficit) / games
+		t.Logf("level %d: deficit %.3f", n+1, d)
+		if n > 0 && d >= last {
+			t.Errorf("level %d: deficit %.3f, not below level %d's %.3f", n+1, d, n, last)
+		}
+		last = d
+	}
+	if last != 0 {
+		t.Errorf("level 10: deficit %.3f, want 0", last)
+	}
+}
@@ -0,0 +1,138 @@
+package bsquare
+
+import (
+	"math"
+	"sync"
+)
+
+// Expectimax maximizes the expected final score against a model of an
+// imperfect opponent, rather than assuming perfect opposition. The
+// opponent plays each legal move with probability proportional to
+// exp(Beta*v), where v is the move's perfect score for the opponent, so
+// Beta 0 models a uniformly random player and larger Beta ever stronger
+// ones. Table is only needed when Beta is nonzero.
+//
+// Expected scores are memoized by canonical state for each side the
+// engine plays, filling up to the size of a full solve.
+type Expectimax struct {
+	Table Minimax
+	Beta  float64
+
+	mu     sync.Mutex
+	values [2]map[State]float32
+	stats  *SearchStats // counts the current move, if any
+}
+
+// NewExpectimax returns an expectimax engine for a softmax opponent.
+func NewExpectimax(t Minimax, beta float64) *Expectimax {
+	return &Expectimax{Table: t, Beta: beta}
+}
+
+// Move plays the move with the best expected score.
+func (e *Expectimax) Move(s State, m Mask) int {
+	i, _ := e.MoveStats(s, m)
+	return i
+}
+
+// MoveStats is Move, also reporting the search effort. Positions already
+// memoized count as hits, and the table size is the side's memo.
+func (e *Expectimax) MoveStats(s State, m Mask) (int, SearchStats) {
+	if s.NoMoves(m) {
+		return -1, SearchStats{}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	side := s.ToMove()
+	best, bestValue := -1, math.Inf(-1)
+	st := measure(s, func(st *SearchStats) {
+		e.stats = st
+		for _, i := range legal(m) {
+			v := e.value(side, s.Place(i), m.Place(i)) * float64(side.Sign())
+			if v > bestValue {
+				best, bestValue = i, v
+			}
+		}
+		e.stats = nil
+	})
+	st.TableSize = len(e.values[side])
+	return best, st
+}
+
+// Expected returns the expected score of a game state, from the first
+// player's perspective, when the engine plays side against the model.
+func (e *Expectimax) Expected(side Player, s State, m Mask) float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.value(side, s, m)
+}
+
+func (e *Expectimax) value(side Player, s State, m Mask) float64 {
+	if e.values[side] == nil {
+		e.values[side] = make(map[State]float32)
+	}
+	if st := e.stats; st != nil {
+		st.Nodes++
+		st.MaxDepth = max(st.MaxDepth, s.Turn())
+	}
+	c := s.Canonicalize()
+	if v, ok := e.values[side][c]; ok {
+		if e.stats != nil {
+			e.stats.Hits++
+		}
+		return float64(v)
+	}
+
+	var v float64
+	switch {
+	case s.IsComplete(m):
+		v = float64(s.Score())
+	case s.NoMoves(m):
+		v = e.value(side, s.Pass(), m.Pass())
+	case s.ToMove() == side:
+		v = math.Inf(-side.Sign())
+		for _, i := range legal(m) {
+			w := e.value(side, s.Place(i), m.Place(i))
+			if side == FirstPlayer {
+				v = max(v, w)
+			} else {
+				v = min(v, w)
+			}
+		}
+	default:
+		moves, p := e.model(s, m)
+		for k, i := range moves {
+			v += p[k] * e.value(side, s.Place(i), m.Place(i))
+		}
+	}
+	e.values[side][c] = float32(v)
+	return v
+}
+
+// model returns the opponent's legal moves and their probabilities.
+func (e *Expectimax) model(s State, m Mask) ([]int, []float64) {
+	moves := legal(m)
+	p := make([]float64, len(moves))
+	if e.Beta == 0 {
+		for k := range p {
+			p[k] = 1 / float64(len(p))
+		}
+		return moves, p
+	}
+
+	sign := float64(s.ToMove().Sign())
+	best := math.Inf(-1)
+	for k, i := range moves {
+		p[k] = sign * float64(e.Table.Evaluate(s.Place(i), m.Place(i)))
+		best = max(best, p[k])
+	}
+	var sum float64
+	for k := range p {
+		// Shift by the best score to keep exp in range
+		p[k] = math.Exp(e.Beta * (p[k] - best))
+		sum += p[k]
+	}
+	for k := range p {
+		p[k] /= sum
+	}
+	return moves, p
+}
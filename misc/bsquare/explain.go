@@ -0,0 +1,53 @@
+package bsquare
+
+// SquareAnalysis describes the value of an open square to both players.
+// Scores are from the first player's perspective.
+type SquareAnalysis struct {
+	Square   int  // 0-indexed square
+	CanPlay  bool // legal for the player to move
+	Play     int  // score after the player to move takes the square
+	CanTake  bool // legal for the opponent
+	Take     int  // score had the opponent taken the square instead
+	Critical bool // open to both, and the score depends on who takes it
+}
+
+// Explain analyzes every square still open to either player. The
+// opponent's counterfactual is the player to move passing and the
+// opponent taking the square, so the player to move keeps the move.
+func (t Minimax) Explain(s State, m Mask) []SquareAnalysis {
+	if s.IsComplete(m) {
+		return nil
+	}
+	var squares []SquareAnalysis
+	for i := 0; i < 5*5; i++ {
+		a := SquareAnalysis{Square: i}
+		if m.Valid(i) {
+			a.CanPlay = true
+			a.Play = t.Evaluate(s.Place(i), m.Place(i))
+		}
+		if s, m := s.Pass(), m.Pass(); m.Valid(i) {
+			a.CanTake = true
+			a.Take = t.Evaluate(s.Place(i), m.Place(i))
+		}
+		a.Critical = a.CanPlay && a.CanTake && a.Play != a.Take
+		if a.CanPlay || a.CanTake {
+			squares = append(squares, a)
+		}
+	}
+	return squares
+}
+
+// explainTable adds the square analysis to a report.
+func explainTable(r *Report, squares []SquareAnalysis) {
+	tab := r.Table("Squares", "square", "play", "opponent", "critical")
+	for _, a := range squares {
+		var play, take any
+		if a.CanPlay {
+			play = signed(a.Play)
+		}
+		if a.CanTake {
+			take = signed(a.Take)
+		}
+		tab.Add(a.Square+1, play, take, a.Critical)
+	}
+}
@@ -0,0 +1,200 @@
+package bsquare
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Game is a game history from the empty board under some rules. Game
+// files list the moves as whitespace-separated squares 1-25, or "pass",
+// with "#" comments running to the end of the line. Forced passes may
+// be omitted, and are when written.
+type Game struct {
+	Moves []int // square index of each turn, or -1 for a pass
+	Rules Rules
+}
+
+// Position returns the current game state and mask.
+func (g *Game) Position() (State, Mask) {
+	var s State
+	m := g.Rules.Start()
+	for _, i := range g.Moves {
+		s, m = child(s, m, i)
+	}
+	return s, m
+}
+
+// History returns every game state from the empty board to the current.
+func (g *Game) History() []State {
+	var s State
+	m := g.Rules.Start()
+	states := []State{s}
+	for _, i := range g.Moves {
+		s, m = child(s, m, i)
+		states = append(states, s)
+	}
+	return states
+}
+
+// Over indicates if the game has ended: no moves remain, or under
+// voluntary passes, a position repeated, as when both players pass in a
+// row.
+func (g *Game) Over() bool {
+	s, m := g.Position()
+	return g.over(s, m)
+}
+
+func (g *Game) over(s State, m Mask) bool {
+	if g.Rules.VoluntaryPass && g.Repetition() >= 0 {
+		return true
+	}
+	return g.Rules.IsComplete(s, m)
+}
+
+// Repetition returns the turn at which the current position, the board
+// and the player to move, first occurred, or -1 if it has not repeated.
+// Placements only add pieces, so only passes can repeat a position.
+func (g *Game) Repetition() int {
+	states := g.History()
+	last := repetitionKey(states[len(states)-1])
+	for n, s := range states[:len(states)-1] {
+		if repetitionKey(s) == last {
+			return n
+		}
+	}
+	return -1
+}
+
+// repetitionKey returns a state without its turn count, which passes
+// advance, leaving only the board and the player to move.
+func repetitionKey(s State) State {
+	return s&0x3ffffffffffff | State(s.ToMove())<<50
+}
+
+// Result returns the Result header for the game: "*" while unfinished,
+// and "0" for a repetition drawn under the rules.
+func (g *Game) Result() string {
+	switch score := g.Score(); {
+	case !g.Over():
+		return "*"
+	case score == 0:
+		return "0"
+	default:
+		return fmt.Sprintf("%+d", score)
+	}
+}
+
+// Score returns the score of the current position, which is zero for a
+// repetition drawn under the rules.
+func (g *Game) Score() int {
+	s, _ := g.Position()
+	if g.Rules.RepetitionDraw && g.Repetition() >= 0 {
+		return 0
+	}
+	return s.Score()
+}
+
+// Play a placement at a square index, first passing if the player to move
+// has no legal moves.
+func (g *Game) Play(i int) error {
+	s, m := g.Position()
+	for g.Rules.NoMoves(s, m) && !g.over(s, m) {
+		g.Moves = append(g.Moves, -1)
+		s, m = s.Pass(), m.Pass()
+	}
+	if g.over(s, m) {
+		return ErrGameOver
+	}
+	if i < 0 || i >= 25 {
+		return fmt.Errorf("%w: %d", ErrInvalidSquare, i+1)
+	}
+	if !g.Rules.Valid(m, i) {
+		return fmt.Errorf("%w: %d", ErrIllegalMove, i+1)
+	}
+	g.Moves = append(g.Moves, i)
+	return nil
+}
+
+// Pass the turn, which the standard rules only permit when the player to
+// move has no legal moves.
+func (g *Game) Pass() error {
+	s, m := g.Position()
+	switch {
+	case g.over(s, m):
+		return ErrGameOver
+	case !g.Rules.VoluntaryPass && !g.Rules.NoMoves(s, m):
+		return fmt.Errorf("%w: pass while player %v has legal moves", ErrIllegalMove, s.ToMove())
+	}
+	g.Moves = append(g.Moves, -1)
+	return nil
+}
+
+// ParseMoves plays a list of 1-indexed squares, or "pass", from the
+// empty board under the standard rules.
+func ParseMoves(moves []string) (*Game, error) {
+	g := new(Game)
+	if err := g.Extend(moves); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Extend plays a list of 1-indexed squares, or "pass".
+func (g *Game) Extend(moves []string) error {
+	for _, move := range moves {
+		if move == "pass" {
+			if err := g.Pass(); err != nil {
+				return err
+			}
+			continue
+		}
+		i, err := strconv.Atoi(move)
+		if err != nil || i < 1 || i > 25 {
+			return fmt.Errorf("%w: %q", ErrInvalidSquare, move)
+		}
+		if err := g.Play(i - 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseGame parses a game file, ignoring any headers (see ParseRecord).
+func ParseGame(r io.Reader) (*Game, error) {
+	rec, err := ParseRecord(r)
+	if err != nil {
+		return nil, err
+	}
+	return &rec.Game, nil
+}
+
+// WriteTo writes the game in game file format, omitting forced passes.
+func (g *Game) WriteTo(w io.Writer) (int64, error) {
+	var buf strings.Builder
+	var s State
+	m := g.Rules.Start()
+	sep := ""
+	for _, i := range g.Moves {
+		if i >= 0 {
+			fmt.Fprintf(&buf, "%s%d", sep, i+1)
+			sep = " "
+		} else if !g.Rules.NoMoves(s, m) {
+			fmt.Fprintf(&buf, "%spass", sep)
+			sep = " "
+		}
+		s, m = child(s, m, i)
+	}
+	buf.WriteByte('\n')
+	n, err := io.WriteString(w, buf.String())
+	return int64(n), err
+}
+
+// child returns the state after a move, where -1 passes.
+func child(s State, m Mask, i int) (State, Mask) {
+	if i < 0 {
+		return s.Pass(), m.Pass()
+	}
+	return s.Place(i), m.Place(i)
+}
@@ -0,0 +1,115 @@
+package bsquare
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Handler serves position analysis over HTTP from any evaluator. It
+// holds no global state, routes relative to where it is mounted, and
+// needs no Go 1.22 route patterns, so other services may embed it, as
+// serve does:
+//
+//	mux.Handle("/analysis/", http.StripPrefix("/analysis", bsquare.NewHandler(t)))
+//
+// GET /analyze?p=POSITION analyzes a position string or ID, including
+// the search effort when the evaluator is Measured. Evaluators need not
+// be safe for concurrent use, so requests are serialized.
+type Handler struct {
+	mu   sync.Locker
+	eval Evaluator
+	mux  *http.ServeMux
+}
+
+// NewHandler returns an analysis handler backed by an evaluator.
+func NewHandler(e Evaluator) *Handler {
+	h := &Handler{mu: new(sync.Mutex), eval: e, mux: http.NewServeMux()}
+	h.mux.HandleFunc("/analyze", h.analyze) // no method pattern before Go 1.22
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// analysis is the JSON analysis of a position. Squares are 1-indexed,
+// players are 1 or 2, and 0 means none. Scores are from the first
+// player's perspective.
+type analysis struct {
+	Position string       `json:"position"`
+	ID       string       `json:"id"`
+	Turn     int          `json:"turn"`
+	ToMove   int          `json:"to_move"`
+	Over     bool         `json:"over"`
+	Score    int          `json:"score"`
+	Outcome  string       `json:"outcome"`
+	Moves    []moveScore  `json:"moves"`
+	Best     []int        `json:"best"`
+	Assess   Assessment   `json:"assessment"`
+	Stats    *SearchStats `json:"stats,omitempty"`
+}
+
+type moveScore struct {
+	Square int `json:"square"`
+	Score  int `json:"score"`
+}
+
+func (h *Handler) analyze(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	s, err := ParsePosition(r.URL.Query().Get("p"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	m := s.Derive()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	measured, ok := h.eval.(Measured)
+	var stats *SearchStats
+	if ok {
+		stats = new(SearchStats)
+	}
+	evaluate := func(s State, m Mask) int {
+		if stats == nil {
+			return h.eval.Evaluate(s, m)
+		}
+		v, st := measured.EvaluateStats(s, m)
+		stats.Add(st)
+		return v
+	}
+
+	score := evaluate(s, m)
+	a := analysis{
+		Position: s.String(),
+		ID:       EncodeID(s),
+		Turn:     s.Turn(),
+		Over:     s.IsComplete(m),
+		Score:    score,
+		Outcome:  ScoreOutcome(score).String(),
+		Moves:    []moveScore{},
+		Best:     []int{},
+		Assess:   assess(s, m),
+	}
+	a.Assess.Score = score
+	if !a.Over {
+		a.ToMove = int(s.ToMove()) + 1
+		best := 0
+		for _, i := range legal(m) {
+			v := evaluate(s.Place(i), m.Place(i))
+			a.Moves = append(a.Moves, moveScore{i + 1, v})
+			if v *= s.ToMove().Sign(); len(a.Best) == 0 || v > best {
+				best, a.Best = v, append(a.Best[:0], i+1)
+			} else if v == best {
+				a.Best = append(a.Best, i+1)
+			}
+		}
+	}
+	a.Stats = stats
+	writeJSON(w, http.StatusOK, a)
+}
@@ -0,0 +1,140 @@
+package bsquare
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Headers identify a game record, like the tag roster of a chess PGN.
+// Empty headers are omitted when writing.
+type Headers struct {
+	Event   string
+	Date    string    // YYYY-MM-DD
+	Players [2]string // first and second player
+	Rules   string    // "standard" unless a variant, as named by Rules
+	Result  string    // final score, e.g. "+2" or "0", or "*" if unfinished
+}
+
+// headerTags are the game file tag names, in the order written.
+var headerTags = []string{"Event", "Date", "First", "Second", "Rules", "Result"}
+
+// field returns the header for a game file tag name, or nil if unknown.
+func (h *Headers) field(tag string) *string {
+	switch tag {
+	case "Event":
+		return &h.Event
+	case "Date":
+		return &h.Date
+	case "First":
+		return &h.Players[0]
+	case "Second":
+		return &h.Players[1]
+	case "Rules":
+		return &h.Rules
+	case "Result":
+		return &h.Result
+	}
+	return nil
+}
+
+// ParseRecord parses a game file with optional headers: lines of the
+// form [Tag "value"] before the moves, with tags First and Second for
+// the players. The moves are checked against the Rules header. Unknown
+// tags are ignored.
+func ParseRecord(r io.Reader) (*Record, error) {
+	var h Headers
+	var moves []string
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "[") {
+			moves = append(moves, strings.Fields(line)...)
+			continue
+		}
+		if len(moves) > 0 {
+			return nil, fmt.Errorf("line %d: header after moves", n)
+		}
+		tag, value, ok := strings.Cut(strings.TrimSuffix(line[1:], "]"), " ")
+		if !ok || !strings.HasSuffix(line, "]") {
+			return nil, fmt.Errorf("line %d: invalid header", n)
+		}
+		value, err := strconv.Unquote(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid header value", n)
+		}
+		if f := h.field(tag); f != nil {
+			*f = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	rules, err := ParseRules(h.Rules)
+	if err != nil {
+		return nil, err
+	}
+	g := &Game{Rules: rules}
+	if err := g.Extend(moves); err != nil {
+		return nil, err
+	}
+	rec := NewRecord(g)
+	rec.Headers = h
+	return rec, nil
+}
+
+// WriteGame writes the record in game file format, headers first. A
+// variant game always has a Rules header.
+func (r *Record) WriteGame(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	h := r.Headers
+	if h.Rules == "" && r.Game.Rules != (Rules{}) {
+		h.Rules = r.Game.Rules.String()
+	}
+	tagged := false
+	for _, tag := range headerTags {
+		if v := *h.field(tag); v != "" {
+			fmt.Fprintf(buf, "[%s %s]\n", tag, strconv.Quote(v))
+			tagged = true
+		}
+	}
+	if tagged {
+		buf.WriteByte('\n')
+	}
+	if _, err := r.Game.WriteTo(buf); err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+// sgfResult converts a Result header to an SGF RE value, with the first
+// player as black.
+func sgfResult(result string) string {
+	switch {
+	case result == "*":
+		return "?"
+	case strings.HasPrefix(result, "+"):
+		return "B" + result
+	case strings.HasPrefix(result, "-"):
+		return "W+" + result[1:]
+	}
+	return result
+}
+
+// parseSGFResult converts an SGF RE value to a Result header.
+func parseSGFResult(re string) string {
+	switch {
+	case re == "?" || re == "Void":
+		return "*"
+	case strings.HasPrefix(re, "B+"):
+		return re[1:]
+	case strings.HasPrefix(re, "W+"):
+		return "-" + re[2:]
+	case re == "Draw":
+		return "0"
+	}
+	return re
+}
@@ -0,0 +1,377 @@
+package bsquare
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Heatmap is a statistic for each square, shaded by value. Squares
+// without a value are NaN. Signed maps shade positive values in the
+// first player's color and negative in the second's, and others shade
+// from zero to the largest value.
+type Heatmap struct {
+	Stat   string
+	Values [25]float64
+	Signed bool
+}
+
+// heatColors are the players' colors, matching svgColors.
+var heatColors = [2]color.RGBA{{0x3b, 0x78, 0xff, 0xff}, {0xe7, 0x48, 0x56, 0xff}}
+
+// scale returns a square's value scaled to [-1, 1], or NaN.
+func (h *Heatmap) scale(i int) float64 {
+	var top float64
+	for _, v := range h.Values {
+		if !math.IsNaN(v) {
+			top = max(top, math.Abs(v))
+		}
+	}
+	switch v := h.Values[i]; {
+	case math.IsNaN(v):
+		return v
+	case top == 0:
+		return 0
+	default:
+		return v / top
+	}
+}
+
+// color returns the shade of a square.
+func (h *Heatmap) color(i int) color.RGBA {
+	t := h.scale(i)
+	if math.IsNaN(t) {
+		return color.RGBA{0xd0, 0xd0, 0xd0, 0xff}
+	}
+	full := color.RGBA{0x40, 0x40, 0x40, 0xff}
+	if h.Signed {
+		full = heatColors[0]
+		if t < 0 {
+			full, t = heatColors[1], -t
+		}
+	}
+	blend := func(c uint8) uint8 {
+		return uint8(math.Round(0xff + t*(float64(c)-0xff)))
+	}
+	return color.RGBA{blend(full.R), blend(full.G), blend(full.B), 0xff}
+}
+
+// label formats a square's value, or "-" without one.
+func (h *Heatmap) label(i int) string {
+	v := h.Values[i]
+	switch {
+	case math.IsNaN(v):
+		return "-"
+	case h.Signed:
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	}
+	return strconv.FormatFloat(v, 'f', 3, 64)
+}
+
+// PrintHeatmap draws a heatmap as a grid of values, shaded with ANSI
+// colors, or with a block character of matching density without color.
+func (t *Theme) PrintHeatmap(w io.Writer, h *Heatmap) error {
+	width := 0
+	for i := range h.Values {
+		width = max(width, len(h.label(i)))
+	}
+	shades := []string{" ", "░", "▒", "▓", "█"}
+	buf := bufio.NewWriter(w)
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 5; x++ {
+			i := y*5 + x
+			cell := fmt.Sprintf(" %*s ", width, h.label(i))
+			if t.Color {
+				c := h.color(i)
+				level := func(v uint8) int { return (int(v)*5 + 127) / 255 }
+				cube := 16 + 36*level(c.R) + 6*level(c.G) + level(c.B)
+				buf.WriteString(t.paint(cell, "30", "48;5;"+strconv.Itoa(cube)))
+				continue
+			}
+			shade := " "
+			if v := h.scale(i); !math.IsNaN(v) {
+				shade = shades[int(math.Round(math.Abs(v)*float64(len(shades)-1)))]
+			}
+			buf.WriteString(cell + shade)
+		}
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	return buf.Flush()
+}
+
+// writeSVG draws the heatmap as shaded squares labeled with values.
+func (h *Heatmap) writeSVG(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	size := 5 * svgSquare
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		size, size, size, size)
+	for i := 0; i < 5*5; i++ {
+		x, y := i%5*svgSquare, i/5*svgSquare
+		c := h.color(i)
+		fmt.Fprintf(buf, `<rect x="%d" y="%d" width="%d" height="%d" fill="#%02x%02x%02x" stroke="#808080"/>`+"\n",
+			x, y, svgSquare, svgSquare, c.R, c.G, c.B)
+		fmt.Fprintf(buf, `<text x="%d" y="%d" text-anchor="middle" dominant-baseline="central" `+
+			`font-family="sans-serif" font-size="13">%s</text>`+"\n",
+			x+svgSquare/2, y+svgSquare/2, h.label(i))
+	}
+	buf.WriteString("</svg>\n")
+	return buf.Flush()
+}
+
+// WritePNG draws the heatmap as a PNG image of shaded squares.
+func (h *Heatmap) WritePNG(w io.Writer) error {
+	size := 5*svgSquare + 1
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	grid := color.RGBA{0x80, 0x80, 0x80, 0xff}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if x%svgSquare == 0 || y%svgSquare == 0 {
+				img.SetRGBA(x, y, grid)
+			} else {
+				img.SetRGBA(x, y, h.color(y/svgSquare*5+x/svgSquare))
+			}
+		}
+	}
+	return png.Encode(w, img)
+}
+
+// jsonValues returns the values by square with nulls for NaN.
+func (h *Heatmap) jsonValues() []any {
+	values := make([]any, len(h.Values))
+	for i, v := range h.Values {
+		if !math.IsNaN(v) {
+			values[i] = v
+		}
+	}
+	return values
+}
+
+// occupancy is the fraction of games ending with each player's piece
+// on each square.
+type occupancy [2][25]float64
+
+// SquareOccupancy returns the occupancy over the perfect games from a
+// game state, with every perfect game counting equally.
+func SquareOccupancy(p *PerfectGames, s State, m Mask) (*occupancy, error) {
+	o := occupancies{p, make(map[State]*occupancy)}
+	return o.get(s, m)
+}
+
+// occupancies memoizes occupancy by canonical state.
+type occupancies struct {
+	p    *PerfectGames
+	memo map[State]*occupancy
+}
+
+func (o *occupancies) get(s State, m Mask) (*occupancy, error) {
+	// Compute in the canonical orientation, then map back
+	tr := s.CanonicalTransform()
+	c := tr.Apply(s)
+	occ, ok := o.memo[c]
+	if !ok {
+		var err error
+		if occ, err = o.compute(c, tr.ApplyMask(m)); err != nil {
+			return nil, err
+		}
+		o.memo[c] = occ
+	}
+	oriented := new(occupancy)
+	for i := 0; i < 25; i++ {
+		j := tr.ApplySquare(i)
+		oriented[0][i], oriented[1][i] = occ[0][j], occ[1][j]
+	}
+	return oriented, nil
+}
+
+// compute averages the occupancy after each perfect move, weighted by
+// the number of perfect games following it.
+func (o *occupancies) compute(s State, m Mask) (*occupancy, error) {
+	occ := new(occupancy)
+	if s.IsComplete(m) {
+		for i := 0; i < 25; i++ {
+			occ[0][i] = float64(s >> i & 1)
+			occ[1][i] = float64(s >> (i + 25) & 1)
+		}
+		return occ, nil
+	} else if s.NoMoves(m) {
+		return o.get(s.Pass(), m.Pass())
+	}
+	var total float64
+	for _, i := range o.p.Table.Suggest(s, m) {
+		counts, err := o.p.count(s.Place(i), m.Place(i))
+		if err != nil {
+			return nil, err
+		}
+		sub, err := o.get(s.Place(i), m.Place(i))
+		if err != nil {
+			return nil, err
+		}
+		w := float64(counts.total())
+		total += w
+		for who := range occ {
+			for j := range occ[who] {
+				occ[who][j] += w * sub[who][j]
+			}
+		}
+	}
+	for who := range occ {
+		for j := range occ[who] {
+			occ[who][j] /= total
+		}
+	}
+	return occ, nil
+}
+
+// SquareTotals are per-square move totals over positions.
+type SquareTotals struct {
+	Positions [25]int // positions where the square is legal
+	Best      [25]int // positions where it is a perfect move
+	Score     [25]int // sum of the scores after playing it
+}
+
+// SquareStats totals the moves of every solved position at a turn that
+// has moves, in every distinct orientation, with scores from the first
+// player's perspective.
+func SquareStats(ctx context.Context, t Minimax, turn int) (*SquareTotals, error) {
+	st := new(SquareTotals)
+	n := 0
+	for s := range t {
+		if s.Turn() != turn {
+			continue
+		}
+		if n++; n&0xffff == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		m := s.Derive()
+		if s.IsComplete(m) || s.NoMoves(m) {
+			continue
+		}
+		score := t.Evaluate(s, m)
+		moves := legal(m)
+		scores := make([]int, len(moves))
+		for k, i := range moves {
+			scores[k] = t.Evaluate(s.Place(i), m.Place(i))
+		}
+		var seen [8]State
+		for tr := Transform(0); tr < 8; tr++ {
+			seen[tr] = tr.Apply(s)
+			dup := false
+			for _, prev := range seen[:tr] {
+				dup = dup || prev == seen[tr]
+			}
+			if dup {
+				continue
+			}
+			for k, i := range moves {
+				j := tr.ApplySquare(i)
+				st.Positions[j]++
+				st.Score[j] += scores[k]
+				if scores[k] == score {
+					st.Best[j]++
+				}
+			}
+		}
+	}
+	return st, nil
+}
+
+var heatStats = []string{"occupied", "owner", "eval", "best"}
+
+// heatmapMain implements the "heatmap" command.
+func heatmapMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("heatmap", flag.ContinueOnError)
+	stat := flags.String("stat", "occupied", "statistic ("+strings.Join(heatStats, ", ")+")")
+	turn := flags.Int("turn", 0, "turn of the positions for eval and best")
+	pngPath := flags.String("png", "", "also draw the heatmap to a PNG `file`")
+	in := addInput(flags)
+	format := addFormat(flags, "text")
+	display := addTheme(flags)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if err := display.apply(); err != nil {
+		return err
+	}
+	if *turn < 0 || *turn >= 50 {
+		return fmt.Errorf("invalid turn: %d", *turn)
+	}
+	s, m, err := in.position(flags.Args())
+	if err != nil {
+		return err
+	}
+	t, err := Solved(ctx)
+	if err != nil {
+		return err
+	}
+
+	h := &Heatmap{Stat: *stat}
+	r := &Report{Heat: h}
+	squares := r.Table("Squares", "square", *stat)
+	switch *stat {
+	case "occupied", "owner":
+		if s.IsComplete(m) {
+			return ErrGameOver
+		}
+		occ, err := SquareOccupancy(NewPerfectGames(t), s, m)
+		if err != nil {
+			return err
+		}
+		squares.Columns = []string{"square", "first", "second", *stat}
+		h.Signed = *stat == "owner"
+		for i := range h.Values {
+			h.Values[i] = occ[0][i] + occ[1][i]
+			if h.Signed {
+				h.Values[i] = occ[0][i] - occ[1][i]
+			}
+			squares.Add(i+1, occ[0][i], occ[1][i], h.Values[i])
+		}
+		r.Add("Position", s)
+	case "eval", "best":
+		st, err := SquareStats(ctx, t, *turn)
+		if err != nil {
+			return err
+		}
+		squares.Columns = []string{"square", "positions", *stat}
+		h.Signed = *stat == "eval"
+		for i := range h.Values {
+			h.Values[i] = math.NaN()
+			if n := st.Positions[i]; n > 0 {
+				h.Values[i] = float64(st.Best[i]) / float64(n)
+				if h.Signed {
+					h.Values[i] = float64(st.Score[i]) / float64(n)
+				}
+				squares.Add(i+1, n, h.Values[i])
+			} else {
+				squares.Add(i+1, n, nil)
+			}
+		}
+		r.Add("Turn", *turn)
+	default:
+		return fmt.Errorf("unknown statistic: %s", *stat)
+	}
+
+	if *pngPath != "" {
+		f, err := os.Create(*pngPath)
+		if err != nil {
+			return err
+		}
+		if err := h.WritePNG(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return format.Write(os.Stdout, r)
+}
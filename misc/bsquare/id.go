@@ -0,0 +1,253 @@
+package bsquare
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Validate checks that a state could arise in play: at most 50 turns,
+// no square held by both players, no piece adjacent to an opposing
+// piece, no center opening, and piece counts consistent with the turn
+// count under forced passes.
+func (s State) Validate() error {
+	p0 := uint64(s) & 0x1ffffff
+	p1 := uint64(s) >> 25 & 0x1ffffff
+	turn := s.Turn()
+	if turn > 50 {
+		return errors.New("turn out of range")
+	}
+	if s.Pieces(FirstPlayer) > (turn+1)/2 || s.Pieces(SecondPlayer) > turn/2 {
+		return errors.New("too many pieces for turn")
+	}
+	if p0 == 1<<12 {
+		return errors.New("first player opened in the center")
+	}
+	for i := 0; i < 25; i++ {
+		if p0>>i&1 == 1 && p1&uint64(masks[i]) != 0 {
+			return fmt.Errorf("pieces adjacent at square %d", i+1)
+		}
+	}
+	return s.validatePasses()
+}
+
+// validatePasses checks that every pass was forced. A player out of
+// moves stays so, passing each turn after their last piece while the
+// other places pieces, and the game ends once neither can move. So at
+// most one player has passed, the game did not continue past its end,
+// and the passing player had no moves left against some earlier subset
+// of the opposing pieces: as many as had been placed by their first
+// pass.
+func (s State) validatePasses() error {
+	turn := s.Turn()
+	var passes [2]int
+	for who := FirstPlayer; who <= SecondPlayer; who++ {
+		passes[who] = (turn+1-int(who))/2 - s.Pieces(who)
+	}
+	if passes[FirstPlayer] > 0 && passes[SecondPlayer] > 0 {
+		return errors.New("both players passed")
+	}
+	if turn > 0 && passes[TurnPlayer(turn-1)] > 0 && s.DeriveLoose().IsComplete() {
+		return errors.New("turns past the end of the game")
+	}
+	for who := FirstPlayer; who <= SecondPlayer; who++ {
+		if passes[who] == 0 {
+			continue
+		}
+		own := uint32(s>>who.offset()) & 0x1ffffff
+		other := uint32(s>>who.Other().offset()) & 0x1ffffff
+		placed := s.Pieces(who) + int(who) // opposing pieces by the first pass
+		if !covers(0x1ffffff&^own, other, placed) {
+			return fmt.Errorf("player %v passed with moves left", who)
+		}
+	}
+	return nil
+}
+
+// covers reports if at most n of the pieces (a 25-bit board) block all
+// of the squares in need, each piece blocking its own square and its
+// neighbors.
+func covers(need, pieces uint32, n int) bool {
+	if need == 0 {
+		return true
+	} else if n == 0 {
+		return false
+	}
+	u := bits.TrailingZeros32(need)
+	for c := uint32(masks[u]) & pieces; c != 0; c &= c - 1 {
+		i := bits.TrailingZeros32(c)
+		if covers(need&^uint32(masks[i]), pieces&^(1<<i), n-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// EncodeID encodes a state as a short URL-safe position ID.
+func EncodeID(s State) string {
+	var buf [7]byte
+	for i := range buf {
+		buf[i] = byte(s >> (48 - 8*i))
+	}
+	return base64.RawURLEncoding.EncodeToString(buf[:])
+}
+
+// DecodeID decodes and validates a position ID from EncodeID. The mask
+// for the state is then available via Derive.
+func DecodeID(id string) (State, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil || len(buf) != 7 {
+		return 0, fmt.Errorf("invalid position ID: %q", id)
+	}
+	var s State
+	for _, b := range buf {
+		s = s<<8 | State(b)
+	}
+	if err := s.Validate(); err != nil {
+		return 0, fmt.Errorf("invalid position ID %q: %v", id, err)
+	}
+	return s, nil
+}
+
+// String returns the position string for a state: five rows of "x" for
+// the first player, "o" for the second player, and "." for empty, joined
+// with "/", followed by ":" and the turn count.
+func (s State) String() string {
+	var buf strings.Builder
+	for i := 0; i < 25; i++ {
+		if i > 0 && i%5 == 0 {
+			buf.WriteByte('/')
+		}
+		switch {
+		case s>>i&1 == 1:
+			buf.WriteByte('x')
+		case s>>(i+25)&1 == 1:
+			buf.WriteByte('o')
+		default:
+			buf.WriteByte('.')
+		}
+	}
+	fmt.Fprintf(&buf, ":%d", s.Turn())
+	return buf.String()
+}
+
+// ParsePosition parses and validates either a position string or a
+// position ID. The turn count may be omitted from a position string when
+// no player has passed.
+func ParsePosition(str string) (State, error) {
+	if !strings.Contains(str, "/") {
+		return DecodeID(str)
+	}
+
+	board, turn, hasTurn := strings.Cut(str, ":")
+	rows := strings.Split(board, "/")
+	if len(rows) != 5 {
+		return 0, fmt.Errorf("invalid position: %q", str)
+	}
+	var s State
+	for y, row := range rows {
+		if len(row) != 5 {
+			return 0, fmt.Errorf("invalid position: %q", str)
+		}
+		for x, c := range []byte(row) {
+			i := y*5 + x
+			switch c {
+			case 'x':
+				s |= 1 << i
+			case 'o':
+				s |= 1 << (i + 25)
+			case '.':
+			default:
+				return 0, fmt.Errorf("invalid position: %q", str)
+			}
+		}
+	}
+
+	n := s.Pieces(FirstPlayer) + s.Pieces(SecondPlayer)
+	if hasTurn {
+		var err error
+		n, err = strconv.Atoi(turn)
+		if err != nil || n < 0 || n > 50 {
+			return 0, fmt.Errorf("invalid turn: %q", turn)
+		}
+	}
+	s |= State(n) << 50
+	if err := s.Validate(); err != nil {
+		return 0, fmt.Errorf("invalid position %q: %v", str, err)
+	}
+	return s, nil
+}
+
+// MarshalBinary encodes a state as 8 little endian bytes.
+func (s State) MarshalBinary() ([]byte, error) {
+	return binary.LittleEndian.AppendUint64(nil, uint64(s)), nil
+}
+
+// UnmarshalBinary decodes and validates a state from MarshalBinary.
+func (s *State) UnmarshalBinary(data []byte) error {
+	if len(data) != 8 {
+		return errors.New("invalid binary state length")
+	}
+	v := State(binary.LittleEndian.Uint64(data))
+	if v>>56 != 0 {
+		return errors.New("invalid binary state")
+	}
+	if err := v.Validate(); err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
+
+// MarshalText encodes a state as its position string.
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a position string or ID.
+func (s *State) UnmarshalText(text []byte) error {
+	v, err := ParsePosition(string(text))
+	if err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
+
+// MarshalBinary encodes a mask as 8 little endian bytes.
+func (m Mask) MarshalBinary() ([]byte, error) {
+	return binary.LittleEndian.AppendUint64(nil, uint64(m)), nil
+}
+
+// UnmarshalBinary decodes a mask from MarshalBinary.
+func (m *Mask) UnmarshalBinary(data []byte) error {
+	if len(data) != 8 {
+		return errors.New("invalid binary mask length")
+	}
+	v := Mask(binary.LittleEndian.Uint64(data))
+	if v>>56 != 0 {
+		return errors.New("invalid binary mask")
+	}
+	*m = v
+	return nil
+}
+
+// MarshalText encodes a mask in hexadecimal. Masks have no position
+// string since they are derived from a state.
+func (m Mask) MarshalText() ([]byte, error) {
+	return fmt.Appendf(nil, "%014x", uint64(m)), nil
+}
+
+// UnmarshalText decodes a mask from MarshalText.
+func (m *Mask) UnmarshalText(text []byte) error {
+	v, err := strconv.ParseUint(string(text), 16, 56)
+	if err != nil {
+		return fmt.Errorf("invalid mask: %q", text)
+	}
+	*m = Mask(v)
+	return nil
+}
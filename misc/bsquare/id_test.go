@@ -0,0 +1,71 @@
+package bsquare
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestValidate checks Validate against positions known to be reachable
+// or not.
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		position string
+		valid    bool
+	}{
+		{"...../...../...../...../.....:0", true},
+		{"...../.x.../..o../...../.....:2", true},
+		{"...../...../...../...../.....:1", false},
+		{"...../...../...../...../.....:2", false},
+		{"x..../...../...../...../.....:3", false},
+		{"...../...../..x../...../.....:1", false},
+		{"xo.../...../...../...../.....:2", false},
+
+		// The second player has no moves, then passes
+		{"o.xxx/oo..x/oo.x./o.x.o/.xxx.:17", true},
+		{"o.xxx/oo..x/oo.x./o.x.o/.xxx.:18", true},
+		{"o.xxx/oo..x/oo.x./o.x.o/.xxx.:19", false},
+
+		// Game over, and turns past its end
+		{"ooooo/.ooo./x.o.x/xx.xx/xxxxx:21", true},
+		{"ooooo/.ooo./x.o.x/xx.xx/xxxxx:22", false},
+	}
+	for _, c := range cases {
+		_, err := ParsePosition(c.position)
+		if valid := err == nil; valid != c.valid {
+			t.Errorf("%s: got %v, want valid %v", c.position, err, c.valid)
+		}
+	}
+	if err := (State(51) << 50).Validate(); err == nil {
+		t.Error("turn 51 accepted")
+	}
+}
+
+// TestValidateReachable checks Validate against the solved table over
+// positions from random games, and the same positions with turns or
+// pieces removed, so that passes were taken.
+func TestValidateReachable(t *testing.T) {
+	if testing.Short() {
+		t.Skip("solves the whole game")
+	}
+	table := New()
+	table.Evaluate(0, 0)
+	r := rand.New(rand.NewSource(1))
+	for g := 0; g < 10000; g++ {
+		var s State
+		var m Mask
+		for !s.IsComplete(m) {
+			if moves := legal(m); len(moves) == 0 {
+				s, m = s.Pass(), m.Pass()
+			} else {
+				i := moves[r.Intn(len(moves))]
+				s, m = s.Place(i), m.Place(i)
+			}
+			for _, v := range []State{s, s + 1<<50, s + 2<<50, s &^ (1 << r.Intn(50))} {
+				_, reachable := table[v.Canonicalize()]
+				if err := v.Validate(); (err == nil) != reachable {
+					t.Fatalf("%v: got %v, want valid %v", v, err, reachable)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,532 @@
+package bsquare
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Report is a command's results independent of output format: an
+// optional board diagram or heatmap, labeled fields, and tables.
+type Report struct {
+	Board  *Diagram
+	Heat   *Heatmap
+	Fields []Field
+	Tables []*Table
+}
+
+// Field is a labeled result. Text, when set, replaces the value in text
+// output.
+type Field struct {
+	Label string
+	Value any
+	Text  string
+}
+
+// Table is a named table of results. A nil cell is not applicable.
+type Table struct {
+	Name    string
+	Columns []string
+	Rows    [][]any
+}
+
+// Diagram is a board to draw, with the score of each legal move when
+// Table is non-nil, and the highlighted square Mark (-1 for none).
+type Diagram struct {
+	State State
+	Mask  Mask
+	Table Minimax
+	Mark  int
+}
+
+// signed is an integer displayed with its sign, like a score.
+type signed int
+
+func (n signed) String() string {
+	return fmt.Sprintf("%+d", int(n))
+}
+
+// percent is a percentage displayed with one decimal place.
+type percent float64
+
+func (p percent) String() string {
+	return fmt.Sprintf("%.1f%%", float64(p))
+}
+
+// indented is text indented by level in text output only.
+type indented struct {
+	Level int
+	Text  string
+}
+
+func (i indented) String() string {
+	return i.Text
+}
+
+// Add appends a field.
+func (r *Report) Add(label string, value any) {
+	r.Fields = append(r.Fields, Field{Label: label, Value: value})
+}
+
+// AddText appends a field with its own text form.
+func (r *Report) AddText(label string, value any, text string) {
+	r.Fields = append(r.Fields, Field{label, value, text})
+}
+
+// Table appends a new table.
+func (r *Report) Table(name string, columns ...string) *Table {
+	t := &Table{Name: name, Columns: columns}
+	r.Tables = append(r.Tables, t)
+	return t
+}
+
+// Add appends a row, one value per column.
+func (t *Table) Add(values ...any) {
+	t.Rows = append(t.Rows, values)
+}
+
+// Format is an output format, and a flag.Value accepting them.
+type Format string
+
+var formatNames = []string{"text", "json", "csv", "svg"}
+
+func (f *Format) String() string {
+	return string(*f)
+}
+
+func (f *Format) Set(name string) error {
+	for _, n := range formatNames {
+		if name == n {
+			*f = Format(name)
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of %s", strings.Join(formatNames, ", "))
+}
+
+// addFormat registers the -format flag on a command's flag set.
+func addFormat(flags *flag.FlagSet, def Format) *Format {
+	f := def
+	flags.Var(&f, "format", "output `format` ("+strings.Join(formatNames, ", ")+")")
+	return &f
+}
+
+// Write writes a report in the format. Text output draws boards with
+// the current theme. CSV holds the tables, separated by blank lines, or
+// the fields when there are no tables. SVG draws the board diagram or
+// heatmap when there is one and otherwise the text output.
+func (f Format) Write(w io.Writer, r *Report) error {
+	switch f {
+	case "json":
+		return r.writeJSON(w)
+	case "csv":
+		return r.writeCSV(w)
+	case "svg":
+		if r.Board != nil {
+			return r.Board.writeSVG(w)
+		} else if r.Heat != nil {
+			return r.Heat.writeSVG(w)
+		}
+		return r.writeTextSVG(w)
+	}
+	return r.writeText(w, &theme)
+}
+
+// cellText formats a value for text and CSV output.
+func cellText(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "-"
+	case indented:
+		return strings.Repeat("  ", v.Level) + v.Text
+	case float64:
+		return strconv.FormatFloat(v, 'f', 3, 64)
+	case []int:
+		parts := make([]string, len(v))
+		for i, n := range v {
+			parts[i] = strconv.Itoa(n)
+		}
+		return strings.Join(parts, " ")
+	}
+	return fmt.Sprint(v)
+}
+
+// jsonValue converts a value to its JSON representation: durations in
+// seconds, and other named types by their string form.
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case signed:
+		return int(v)
+	case percent:
+		return float64(v)
+	case time.Duration:
+		return v.Seconds()
+	case []int:
+		if v == nil {
+			return []int{}
+		}
+		return v
+	case fmt.Stringer:
+		return v.String()
+	}
+	return v
+}
+
+// key converts a label to a JSON and CSV key, e.g. "Player 1 wins" to
+// "player_1_wins".
+func key(label string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(label) {
+		switch {
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "_"):
+			b.WriteByte('_')
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+func (r *Report) writeText(w io.Writer, th *Theme) error {
+	buf := bufio.NewWriter(w)
+	if d := r.Board; d != nil {
+		if d.Table != nil {
+			th.PrintScores(buf, d.Table, d.State, d.Mask)
+		}
+		th.PrintBoard(buf, d.State, d.Mask, d.Mark)
+	}
+	if r.Heat != nil {
+		th.PrintHeatmap(buf, r.Heat)
+	}
+	for _, f := range r.Fields {
+		text := f.Text
+		if text == "" {
+			text = cellText(f.Value)
+		}
+		fmt.Fprintf(buf, "%s: %s\n", f.Label, text)
+	}
+	for i, t := range r.Tables {
+		if i > 0 || len(r.Fields) > 0 {
+			buf.WriteByte('\n')
+		}
+		t.writeText(buf)
+	}
+	return buf.Flush()
+}
+
+// writeText aligns the columns, numbers to the right and text to the
+// left.
+func (t *Table) writeText(w io.Writer) {
+	cells := make([][]string, len(t.Rows))
+	widths := make([]int, len(t.Columns))
+	left := make([]bool, len(t.Columns))
+	for j, c := range t.Columns {
+		widths[j] = utf8.RuneCountInString(c)
+	}
+	for i, row := range t.Rows {
+		cells[i] = make([]string, len(row))
+		for j, v := range row {
+			cells[i][j] = cellText(v)
+			widths[j] = max(widths[j], utf8.RuneCountInString(cells[i][j]))
+			switch v.(type) {
+			case string, State, indented:
+				left[j] = true
+			}
+		}
+	}
+	line := func(row []string) {
+		var b strings.Builder
+		for j, c := range row {
+			pad := strings.Repeat(" ", widths[j]-utf8.RuneCountInString(c))
+			if j > 0 {
+				b.WriteString("  ")
+			}
+			if left[j] {
+				b.WriteString(c + pad)
+			} else {
+				b.WriteString(pad + c)
+			}
+		}
+		fmt.Fprintln(w, strings.TrimRight(b.String(), " "))
+	}
+	line(t.Columns)
+	for _, row := range cells {
+		line(row)
+	}
+}
+
+// member is one member of a JSON object, which is written in order.
+type member struct {
+	key   string
+	value any
+}
+
+type object []member
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(m.key)
+		v, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// writeJSON writes the report as one object: the board under "board",
+// then the fields, then each table as an array of row objects.
+func (r *Report) writeJSON(w io.Writer) error {
+	var o object
+	if d := r.Board; d != nil {
+		board := object{
+			{"position", d.State.String()},
+			{"id", EncodeID(d.State)},
+			{"turn", d.State.Turn()},
+		}
+		if d.Table != nil {
+			moves := []object{}
+			for _, i := range legal(d.Mask) {
+				score := d.Table.Evaluate(d.State.Place(i), d.Mask.Place(i))
+				moves = append(moves, object{{"square", i + 1}, {"score", score}})
+			}
+			board = append(board, member{"moves", moves})
+		}
+		o = append(o, member{"board", board})
+	}
+	if h := r.Heat; h != nil {
+		o = append(o, member{"heatmap", object{{"stat", h.Stat}, {"values", h.jsonValues()}}})
+	}
+	for _, f := range r.Fields {
+		o = append(o, member{key(f.Label), jsonValue(f.Value)})
+	}
+	for _, t := range r.Tables {
+		rows := []object{}
+		for _, row := range t.Rows {
+			var ro object
+			for j, v := range row {
+				ro = append(ro, member{key(t.Columns[j]), jsonValue(v)})
+			}
+			rows = append(rows, ro)
+		}
+		o = append(o, member{key(t.Name), rows})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(o)
+}
+
+func (r *Report) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if len(r.Tables) == 0 {
+		cw.Write([]string{"field", "value"})
+		for _, f := range r.Fields {
+			cw.Write([]string{key(f.Label), csvText(f.Value)})
+		}
+	}
+	for i, t := range r.Tables {
+		if i > 0 {
+			cw.Flush()
+			io.WriteString(w, "\n")
+		}
+		header := make([]string, len(t.Columns))
+		for j, c := range t.Columns {
+			header[j] = key(c)
+		}
+		cw.Write(header)
+		for _, row := range t.Rows {
+			record := make([]string, len(row))
+			for j, v := range row {
+				record[j] = csvText(v)
+			}
+			cw.Write(record)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvText formats a value for CSV, leaving inapplicable cells empty and
+// giving durations in seconds.
+func csvText(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case indented:
+		return v.Text
+	case time.Duration:
+		return strconv.FormatFloat(v.Seconds(), 'f', -1, 64)
+	}
+	return cellText(v)
+}
+
+// Stream writes a table a row at a time, flushing each, for commands
+// answering input as it arrives. Text rows are tab-separated, leaving
+// off trailing inapplicable cells, JSON rows are one object per line
+// without them, and CSV rows follow a header. SVG cannot be streamed.
+type Stream struct {
+	format  Format
+	columns []string
+	buf     *bufio.Writer
+	csv     *csv.Writer
+}
+
+// Stream starts a table with the given columns.
+func (f Format) Stream(w io.Writer, columns ...string) (*Stream, error) {
+	if f == "svg" {
+		return nil, errors.New("svg output cannot be streamed")
+	}
+	s := &Stream{format: f, columns: columns, buf: bufio.NewWriter(w)}
+	if f == "csv" {
+		s.csv = csv.NewWriter(s.buf)
+		header := make([]string, len(columns))
+		for j, c := range columns {
+			header[j] = key(c)
+		}
+		s.csv.Write(header)
+	}
+	return s, nil
+}
+
+// Add writes a row, one value per column.
+func (s *Stream) Add(values ...any) error {
+	switch s.format {
+	case "json":
+		var o object
+		for j, v := range values {
+			if v != nil {
+				o = append(o, member{key(s.columns[j]), jsonValue(v)})
+			}
+		}
+		if err := json.NewEncoder(s.buf).Encode(o); err != nil {
+			return err
+		}
+	case "csv":
+		record := make([]string, len(values))
+		for j, v := range values {
+			record[j] = csvText(v)
+		}
+		s.csv.Write(record)
+		s.csv.Flush()
+	default:
+		n := len(values)
+		for n > 0 && values[n-1] == nil {
+			n--
+		}
+		cells := make([]string, n)
+		for j, v := range values[:n] {
+			cells[j] = cellText(v)
+		}
+		fmt.Fprintln(s.buf, strings.Join(cells, "\t"))
+	}
+	return s.buf.Flush()
+}
+
+// SVG board colors by player, matching the default theme.
+var svgColors = [2]string{"#3b78ff", "#e74856"}
+
+const svgSquare = 48
+
+// writeSVG draws the diagram with the pieces, the squares claimed by
+// one player, and the score of each legal move, bold for the best.
+func (d *Diagram) writeSVG(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	size := 5 * svgSquare
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		size, size, size, size)
+	best := make(map[int]bool)
+	if d.Table != nil {
+		for _, i := range d.Table.Suggest(d.State, d.Mask) {
+			best[i] = true
+		}
+	}
+	for i := 0; i < 5*5; i++ {
+		x, y := i%5*svgSquare, i/5*svgSquare
+		x0 := d.Mask >> (i + 25) & 1 // second player blocked
+		x1 := d.Mask >> i & 1        // first player blocked
+		fill := "#ffffff"
+		switch {
+		case x0 == 1 && x1 == 1:
+			fill = "#d0d0d0"
+		case x0 == 1:
+			fill = "#dce7ff"
+		case x1 == 1:
+			fill = "#fbdde0"
+		}
+		stroke := "#808080"
+		if i == d.Mark {
+			stroke = "#000000"
+		}
+		fmt.Fprintf(buf, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s" stroke="%s"/>`+"\n",
+			x, y, svgSquare, svgSquare, fill, stroke)
+		cx, cy := x+svgSquare/2, y+svgSquare/2
+		for p := 0; p < 2; p++ {
+			if d.State>>(i+25*p)&1 == 1 {
+				fmt.Fprintf(buf, `<circle cx="%d" cy="%d" r="%d" fill="%s"/>`+"\n",
+					cx, cy, svgSquare*3/8, svgColors[p])
+			}
+		}
+		if d.Table != nil && d.Mask.Valid(i) {
+			score := d.Table.Evaluate(d.State.Place(i), d.Mask.Place(i))
+			color, weight := "#000000", "normal"
+			if score > 0 {
+				color = svgColors[0]
+			} else if score < 0 {
+				color = svgColors[1]
+			}
+			if best[i] {
+				weight = "bold"
+			}
+			fmt.Fprintf(buf, `<text x="%d" y="%d" text-anchor="middle" dominant-baseline="central" `+
+				`font-family="sans-serif" font-size="18" font-weight="%s" fill="%s">%+d</text>`+"\n",
+				cx, cy, weight, color, score)
+		}
+	}
+	buf.WriteString("</svg>\n")
+	return buf.Flush()
+}
+
+// writeTextSVG draws the uncolored text output as monospace lines.
+func (r *Report) writeTextSVG(w io.Writer) error {
+	plain := theme
+	plain.Color = false
+	var text bytes.Buffer
+	if err := r.writeText(&text, &plain); err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimRight(text.String(), "\n"), "\n")
+	width := 0
+	for _, line := range lines {
+		width = max(width, utf8.RuneCountInString(line))
+	}
+	const cw, lh = 9, 18 // character width and line height
+	buf := bufio.NewWriter(w)
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`+"\n",
+		width*cw+2*cw, len(lines)*lh+lh)
+	fmt.Fprintf(buf, `<rect width="100%%" height="100%%" fill="#ffffff"/>`+"\n")
+	for k, line := range lines {
+		fmt.Fprintf(buf, `<text x="%d" y="%d" font-family="monospace" font-size="15" xml:space="preserve">%s</text>`+"\n",
+			cw, (k+1)*lh, html.EscapeString(line))
+	}
+	buf.WriteString("</svg>\n")
+	return buf.Flush()
+}
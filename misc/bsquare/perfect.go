@@ -0,0 +1,174 @@
+package bsquare
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"math/bits"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// gameCounts counts games by their final turn count.
+type gameCounts [64]uint64
+
+var errCountOverflow = errors.New("game count overflows 64 bits")
+
+// PerfectGames counts distinct move sequences from a game state to the
+// end in which both players always play a perfect move, indexed by the
+// final turn count. Forced passes count as turns.
+type PerfectGames struct {
+	Table Minimax
+	memo  map[State]*gameCounts
+}
+
+// NewPerfectGames returns a counter over a solved table.
+func NewPerfectGames(t Minimax) *PerfectGames {
+	return &PerfectGames{Table: t, memo: make(map[State]*gameCounts)}
+}
+
+// Count returns the number of perfect games from a game state, in total
+// and by final turn count.
+func (p *PerfectGames) Count(s State, m Mask) (uint64, *gameCounts, error) {
+	counts, err := p.count(s, m)
+	if err != nil {
+		return 0, nil, err
+	}
+	var total, carry uint64
+	for _, n := range counts {
+		if total, carry = bits.Add64(total, n, 0); carry != 0 {
+			return 0, nil, errCountOverflow
+		}
+	}
+	return total, counts, nil
+}
+
+func (p *PerfectGames) count(s State, m Mask) (*gameCounts, error) {
+	c := s.Canonicalize()
+	if counts, ok := p.memo[c]; ok {
+		return counts, nil
+	}
+	counts := new(gameCounts)
+	if s.IsComplete(m) {
+		counts[s.Turn()] = 1
+	} else {
+		moves := []int{-1}
+		if !s.NoMoves(m) {
+			moves = p.Table.Suggest(s, m)
+		}
+		for _, i := range moves {
+			sub, err := p.count(child(s, m, i))
+			if err != nil {
+				return nil, err
+			}
+			for turn, n := range sub {
+				var carry uint64
+				if counts[turn], carry = bits.Add64(counts[turn], n, 0); carry != 0 {
+					return nil, errCountOverflow
+				}
+			}
+		}
+	}
+	p.memo[c] = counts
+	return counts, nil
+}
+
+// total sums the counts, which Count has already checked for overflow.
+func (c *gameCounts) total() uint64 {
+	var total uint64
+	for _, n := range c {
+		total += n
+	}
+	return total
+}
+
+// Sample returns the moves of a perfect game chosen uniformly at random
+// from all perfect games from a game state.
+func (p *PerfectGames) Sample(s State, m Mask, r *rand.Rand) ([]int, error) {
+	var moves []int
+	for !s.IsComplete(m) {
+		if s.NoMoves(m) {
+			moves = append(moves, -1)
+			s, m = s.Pass(), m.Pass()
+			continue
+		}
+		options := p.Table.Suggest(s, m)
+		weights := make([]uint64, len(options))
+		var total uint64
+		for k, i := range options {
+			counts, err := p.count(s.Place(i), m.Place(i))
+			if err != nil {
+				return nil, err
+			}
+			weights[k] = counts.total()
+			total += weights[k]
+		}
+		pick := r.Uint64() % total
+		k := 0
+		for pick >= weights[k] {
+			pick -= weights[k]
+			k++
+		}
+		moves = append(moves, options[k])
+		s, m = s.Place(options[k]), m.Place(options[k])
+	}
+	return moves, nil
+}
+
+// perfectMain implements the "perfect" command, counting and sampling
+// the perfect games from a position.
+func perfectMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("perfect", flag.ContinueOnError)
+	samples := flags.Int("sample", 0, "number of random perfect games to print")
+	seed := addSeed(flags)
+	in := addInput(flags)
+	format := addFormat(flags, "text")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	s, m, err := in.position(flags.Args())
+	if err != nil {
+		return err
+	}
+	seed.apply()
+	t, err := Solved(ctx)
+	if err != nil {
+		return err
+	}
+
+	p := NewPerfectGames(t)
+	total, counts, err := p.Count(s, m)
+	if err != nil {
+		return err
+	}
+	r := new(Report)
+	r.Add("Perfect games", total)
+	r.Add("Outcome", t.Outcome(s, m))
+	byTurns := r.Table("Games", "turns", "games")
+	for turn, n := range counts {
+		if n > 0 {
+			byTurns.Add(turn, n)
+		}
+	}
+
+	if *samples > 0 {
+		sampled := r.Table("Samples", "moves")
+		rand := engineRand(nil)
+		for k := 0; k < *samples; k++ {
+			moves, err := p.Sample(s, m, rand)
+			if err != nil {
+				return err
+			}
+			var names []string // game file format, with implicit passes
+			for _, i := range moves {
+				if i >= 0 {
+					names = append(names, strconv.Itoa(i+1))
+				}
+			}
+			sampled.Add(strings.Join(names, " "))
+		}
+	}
+	return format.Write(os.Stdout, r)
+}
@@ -0,0 +1,172 @@
+package bsquare
+
+import (
+	"context"
+	"flag"
+	"math/bits"
+	"os"
+)
+
+const pnsInf = 1 << 30
+
+type pnsEntry struct {
+	phi, delta uint32
+}
+
+// PNS is a depth-first proof-number search (df-pn) engine. Rather than
+// computing exact scores, it proves or disproves that the first player
+// can force a final score of at least some threshold, which is usually
+// much cheaper than a full minimax search.
+type PNS struct {
+	Nodes int // total nodes expanded by all searches
+
+	k     int
+	table map[State]pnsEntry
+	stats SearchStats // hits, depth, and peak table of the current search
+}
+
+// NewPNS returns a new proof-number search engine.
+func NewPNS() *PNS {
+	return new(PNS)
+}
+
+// Win reports if the first player can force a win from this game state.
+func (p *PNS) Win(s State, m Mask) bool {
+	return p.Prove(s, m, 1)
+}
+
+// Prove reports if the first player can force a final score of at least
+// k from this game state.
+func (p *PNS) Prove(s State, m Mask, k int) bool {
+	p.k = k
+	p.table = make(map[State]pnsEntry)
+	p.mid(s, m, pnsInf, pnsInf)
+	p.stats.TableSize = max(p.stats.TableSize, len(p.table))
+	e := p.table[s.Canonicalize()]
+	// phi is relative to the player to move
+	return (e.phi == 0) == (s.ToMove() == FirstPlayer)
+}
+
+// Evaluate the minimax score by binary search over proof thresholds,
+// satisfying Evaluator.
+func (p *PNS) Evaluate(s State, m Mask) int {
+	lo, hi := -25, 25 // score lies in [lo, hi]
+	for lo < hi {
+		k := (lo + hi + 1) >> 1
+		if p.Prove(s, m, k) {
+			lo = k
+		} else {
+			hi = k - 1
+		}
+	}
+	return lo
+}
+
+func add(a, b uint32) uint32 {
+	if a+b >= pnsInf {
+		return pnsInf
+	}
+	return a + b
+}
+
+// mid expands a node until its proof numbers, relative to the player to
+// move, reach either threshold.
+func (p *PNS) mid(s State, m Mask, thphi, thdelta uint32) {
+	s0 := s.Canonicalize()
+	e, ok := p.table[s0]
+	if !ok {
+		e = pnsEntry{1, 1}
+	} else {
+		p.stats.Hits++
+	}
+	p.stats.MaxDepth = max(p.stats.MaxDepth, s.Turn())
+	if e.phi >= thphi || e.delta >= thdelta {
+		return
+	}
+	p.Nodes++
+
+	if s.IsComplete(m) {
+		win := s.Score() >= p.k
+		if s.ToMove() == SecondPlayer {
+			win = !win
+		}
+		if win {
+			p.table[s0] = pnsEntry{0, pnsInf}
+		} else {
+			p.table[s0] = pnsEntry{pnsInf, 0}
+		}
+		return
+	}
+
+	type node struct {
+		s State
+		m Mask
+		c State
+	}
+	var children []node
+	if s.NoMoves(m) {
+		children = append(children, node{s.Pass(), m.Pass(), s.Pass().Canonicalize()})
+	}
+	for b := m.LegalBits(m.Turn()); b != 0; b &= b - 1 {
+		i := bits.TrailingZeros32(b)
+		c := node{s.Place(i), m.Place(i), s.Place(i).Canonicalize()}
+		dup := false
+		for _, x := range children {
+			dup = dup || x.c == c.c
+		}
+		if !dup {
+			children = append(children, c)
+		}
+	}
+
+	for {
+		best := -1
+		phi, delta := uint32(pnsInf), uint32(0)
+		var delta2, phi1 uint32 = pnsInf, 0
+		for j, c := range children {
+			ce, ok := p.table[c.c]
+			if !ok {
+				ce = pnsEntry{1, 1}
+			}
+			delta = add(delta, ce.phi)
+			if ce.delta < phi {
+				delta2 = phi
+				phi = ce.delta
+				phi1 = ce.phi
+				best = j
+			} else if ce.delta < delta2 {
+				delta2 = ce.delta
+			}
+		}
+		if phi >= thphi || delta >= thdelta {
+			p.table[s0] = pnsEntry{phi, delta}
+			return
+		}
+		cthphi := thdelta + phi1 - delta
+		cthdelta := thphi
+		if delta2+1 < cthdelta {
+			cthdelta = delta2 + 1
+		}
+		c := children[best]
+		p.mid(c.s, c.m, cthphi, cthdelta)
+	}
+}
+
+// pnsMain implements the "pns" command.
+func pnsMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("pns", flag.ContinueOnError)
+	in := addInput(flags)
+	format := addFormat(flags, "text")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	s, m, err := in.position(flags.Args())
+	if err != nil {
+		return err
+	}
+	win, stats := NewPNS().WinStats(s, m)
+	r := new(Report)
+	r.Add("First player win", win)
+	stats.AddTo(r)
+	return format.Write(os.Stdout, r)
+}
@@ -0,0 +1,185 @@
+package bsquare
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// Policy is a perfect-play strategy for one player: the move at every
+// position reachable under the policy's own moves and all opposing
+// replies, keyed by canonical state. It is far smaller than the full
+// table and needs no search to play.
+type Policy struct {
+	Player Player // player following the policy
+	Moves  map[State]int8
+}
+
+// Policy extracts a perfect-play policy for a player from a solved
+// table. Among equally good moves it prefers the one leading to the
+// fewest policy positions, keeping the policy small.
+func (t Minimax) Policy(s State, m Mask, who Player) *Policy {
+	choices := make(map[State]int8)
+	t.policySize(s, m, who, choices, make(map[State]int))
+	p := &Policy{Player: who, Moves: make(map[State]int8)}
+	p.walk(s, m, choices)
+	return p
+}
+
+// policySize estimates the number of policy positions below a game state,
+// with shared positions counted once per path, recording the smallest
+// perfect move at each of the player's positions.
+func (t Minimax) policySize(s State, m Mask, who Player, choices map[State]int8, sizes map[State]int) int {
+	const limit = 1 << 60
+	c := s.Canonicalize()
+	if n, ok := sizes[c]; ok {
+		return n
+	}
+	n := 0
+	if s.IsComplete(m) {
+		// nothing to decide
+	} else if s.NoMoves(m) {
+		n = t.policySize(s.Pass(), m.Pass(), who, choices, sizes)
+	} else if s.ToMove() == who {
+		best := -1
+		for _, i := range t.Suggest(s, m) {
+			size := t.policySize(s.Place(i), m.Place(i), who, choices, sizes)
+			if best < 0 || size < n {
+				best, n = i, size
+			}
+		}
+		choices[c] = int8(s.CanonicalTransform().ApplySquare(best))
+		n = min(n+1, limit)
+	} else {
+		for _, i := range legal(m) {
+			n = min(n+t.policySize(s.Place(i), m.Place(i), who, choices, sizes), limit)
+		}
+	}
+	sizes[c] = n
+	return n
+}
+
+// walk copies the chosen moves at positions reachable under the policy.
+func (p *Policy) walk(s State, m Mask, choices map[State]int8) {
+	c := s.Canonicalize()
+	if _, ok := p.Moves[c]; ok || s.IsComplete(m) {
+		return
+	}
+	if s.NoMoves(m) {
+		p.walk(s.Pass(), m.Pass(), choices)
+	} else if s.ToMove() == p.Player {
+		p.Moves[c] = choices[c]
+		i := s.CanonicalTransform().Inverse().ApplySquare(int(choices[c]))
+		p.walk(s.Place(i), m.Place(i), choices)
+	} else {
+		for _, i := range legal(m) {
+			p.walk(s.Place(i), m.Place(i), choices)
+		}
+	}
+}
+
+// Move plays the policy's move, passing when there are no legal moves.
+// Outside the policy, such as positions reached by the policy's player
+// deviating, it plays the first legal move.
+func (p *Policy) Move(s State, m Mask) int {
+	moves := legal(m)
+	if len(moves) == 0 {
+		return -1
+	}
+	if j, ok := p.Moves[s.Canonicalize()]; ok {
+		return s.CanonicalTransform().Inverse().ApplySquare(int(j))
+	}
+	return moves[0]
+}
+
+var policyMagic = [4]byte{'B', 'S', 'Q', 'P'}
+
+// WriteTo writes the policy in binary: a magic number, the player, a
+// count, then sorted 9-byte entries of the little endian canonical state
+// and the canonical move.
+func (p *Policy) WriteTo(w io.Writer) (int64, error) {
+	states := make([]State, 0, len(p.Moves))
+	for s := range p.Moves {
+		states = append(states, s)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i] < states[j] })
+
+	buf := bufio.NewWriter(w)
+	buf.Write(policyMagic[:])
+	buf.WriteByte(byte(p.Player))
+	buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(states))))
+	for _, s := range states {
+		var entry [9]byte
+		binary.LittleEndian.PutUint64(entry[:], uint64(s))
+		entry[8] = byte(p.Moves[s])
+		buf.Write(entry[:])
+	}
+	return 9 + 9*int64(len(states)), buf.Flush()
+}
+
+// ReadPolicy reads a policy written by WriteTo.
+func ReadPolicy(r io.Reader) (*Policy, error) {
+	buf := bufio.NewReader(r)
+	var header [9]byte
+	if _, err := io.ReadFull(buf, header[:]); err != nil {
+		return nil, err
+	}
+	if [4]byte(header[:4]) != policyMagic || header[4] > 1 {
+		return nil, errors.New("not a policy")
+	}
+	n := binary.LittleEndian.Uint32(header[5:])
+	if n > solvedStates {
+		return nil, errors.New("policy too large")
+	}
+	p := &Policy{Player: Player(header[4]), Moves: make(map[State]int8)}
+	for i := uint32(0); i < n; i++ {
+		var entry [9]byte
+		if _, err := io.ReadFull(buf, entry[:]); err != nil {
+			return nil, err
+		}
+		if entry[8] >= 25 {
+			return nil, errors.New("invalid policy move")
+		}
+		p.Moves[State(binary.LittleEndian.Uint64(entry[:]))] = int8(entry[8])
+	}
+	return p, nil
+}
+
+// policyMain implements the "policy" command: policy -side N -o FILE
+func policyMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("policy", flag.ContinueOnError)
+	side := flags.Int("side", 1, "player following the policy (1 or 2)")
+	out := flags.String("o", "", "output file (required)")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return errors.New("usage: policy [-side N] -o FILE")
+	}
+	if *side != 1 && *side != 2 {
+		return fmt.Errorf("invalid side: %d", *side)
+	}
+	t, err := Solved(ctx)
+	if err != nil {
+		return err
+	}
+	p := t.Policy(0, 0, Player(*side-1))
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, err := p.WriteTo(f)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Player %v policy: %d positions, %d bytes (table: %d positions)\n",
+		p.Player, len(p.Moves), n, len(t))
+	return f.Close()
+}
@@ -0,0 +1,141 @@
+package bsquare
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Symmetry returns the number of board symmetries mapping this state onto
+// itself: 1 for an asymmetric position up to 8 for a fully symmetric one.
+func (s State) Symmetry() int {
+	n := 0
+	for t := Transform(0); t < 8; t++ {
+		if t.Apply(s) == s {
+			n++
+		}
+	}
+	return n
+}
+
+// Predicate compares one property of a solved position against a value.
+type Predicate struct {
+	Field string // score, turn, pieces(p1), pieces(p2), pieces, sym
+	Op    string // = != < <= > >=
+	Value int
+}
+
+// Query is a conjunction of predicates over solved positions.
+type Query []Predicate
+
+var queryFields = map[string]func(s State, score int) int{
+	"score":      func(s State, score int) int { return score },
+	"turn":       func(s State, score int) int { return s.Turn() },
+	"pieces(p1)": func(s State, score int) int { return s.Pieces(FirstPlayer) },
+	"pieces(p2)": func(s State, score int) int { return s.Pieces(SecondPlayer) },
+	"pieces": func(s State, score int) int {
+		return s.Pieces(FirstPlayer) + s.Pieces(SecondPlayer)
+	},
+	"sym": func(s State, score int) int { return s.Symmetry() },
+}
+
+// ParseQuery parses whitespace-separated predicates such as:
+//
+//	score=0 turn>=8 pieces(p1)=5 sym>1
+func ParseQuery(q string) (Query, error) {
+	var query Query
+	for _, term := range strings.Fields(q) {
+		i := strings.IndexAny(term, "=!<>")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid predicate: %q", term)
+		}
+		p := Predicate{Field: term[:i]}
+		rest := term[i:]
+		for _, op := range []string{"!=", "<=", ">=", "=", "<", ">"} {
+			if strings.HasPrefix(rest, op) {
+				p.Op = op
+				break
+			}
+		}
+		if p.Op == "" {
+			return nil, fmt.Errorf("invalid operator: %q", term)
+		}
+		if queryFields[p.Field] == nil {
+			return nil, fmt.Errorf("unknown field: %q", p.Field)
+		}
+		v, err := strconv.Atoi(rest[len(p.Op):])
+		if err != nil {
+			return nil, fmt.Errorf("invalid value: %q", term)
+		}
+		p.Value = v
+		query = append(query, p)
+	}
+	return query, nil
+}
+
+// Match reports if a position with the given minimax score satisfies
+// every predicate.
+func (q Query) Match(s State, score int) bool {
+	for _, p := range q {
+		v := queryFields[p.Field](s, score)
+		var ok bool
+		switch p.Op {
+		case "=":
+			ok = v == p.Value
+		case "!=":
+			ok = v != p.Value
+		case "<":
+			ok = v < p.Value
+		case "<=":
+			ok = v <= p.Value
+		case ">":
+			ok = v > p.Value
+		case ">=":
+			ok = v >= p.Value
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Query returns the canonical positions in the table matching the query,
+// in increasing order.
+func (t Minimax) Query(q Query) []State {
+	var states []State
+	for s, score := range t {
+		if q.Match(s, int(score)) {
+			states = append(states, s)
+		}
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i] < states[j] })
+	return states
+}
+
+// queryMain implements the "query" command: query PREDICATE...
+func queryMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("query", flag.ContinueOnError)
+	format := addFormat(flags, "text")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	q, err := ParseQuery(strings.Join(flags.Args(), " "))
+	if err != nil {
+		return err
+	}
+	t, err := Solved(ctx)
+	if err != nil {
+		return err
+	}
+	r := new(Report)
+	tab := r.Table("Positions", "position", "score")
+	for _, s := range t.Query(q) {
+		tab.Add(s, signed(t[s]))
+	}
+	return format.Write(os.Stdout, r)
+}
@@ -0,0 +1,217 @@
+package bsquare
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// referencePositions are positions with known values and perfect moves
+// (squares 1-25, or 0 for a forced pass), covering the opening, the
+// midgame, endgames, forced passes, finished games, and symmetric boards.
+var referencePositions = []struct {
+	position string
+	score    int
+	best     []int
+}{
+	// Opening
+	{"...../...../...../...../.....:0", +2, []int{7, 9, 17, 19}},
+	{"...../.x.../...../...../.....:1", +2, []int{3, 9, 10, 11, 13, 14, 15, 17, 18, 19, 22, 23}},
+	{"...../.x.../..o../...../.....:2", +2, []int{15, 19, 23}},
+
+	// Midgame
+	{"...o./.o.../..o../xx.x./.....:6", +1, []int{1, 10, 24}},
+	{".o.../.o..o/...x./o..../.xxx.:8", -1, []int{4, 13, 19, 20, 25}},
+	{"o.xx./.o.../oo.../o.x../..xx.:10", +6, []int{15}},
+
+	// Endgame
+	{".oooo/.o.o./x.o.x/xx.x./.x.x.:14", +2, []int{20, 21, 23, 25}},
+	{".o..o/oo..o/o.xx./o..xx/.xxxx:15", -1, []int{1, 3, 4}},
+
+	// The second player has no moves and must pass
+	{"o.xxx/oo..x/oo.x./o.x.o/.xxx.:17", +3, []int{0}},
+
+	// Game over
+	{"ooooo/.ooo./x.o.x/xx.xx/xxxxx:21", +2, nil},
+
+	// Symmetric boards
+	{"x...x/...../..o../...../.....:3", 0, []int{11, 15, 16, 20, 22, 23, 24}},
+	{"..x../.o.o./x...x/.o.o./..x..:8", +1, []int{1, 5, 13, 21, 25}},
+}
+
+// evaluatorFunc adapts a function to Evaluator.
+type evaluatorFunc func(s State, m Mask) int
+
+func (f evaluatorFunc) Evaluate(s State, m Mask) int {
+	return f(s, m)
+}
+
+// referenceEvaluators lists every evaluator implementation, each made
+// fresh for a run through the reference positions. Slow evaluators are
+// checked in two orientations rather than all eight.
+var referenceEvaluators = []struct {
+	name string
+	new  func() Evaluator
+	slow bool
+}{
+	{"minimax", func() Evaluator { return New() }, false},
+	{"variant", func() Evaluator { return NewVariant(Rules{}) }, false},
+	{"store", func() Evaluator {
+		t := New()
+		return evaluatorFunc(func(s State, m Mask) int {
+			score, _ := SolveStore(context.Background(), t, s, m)
+			return score
+		})
+	}, false},
+	{"retrograde", func() Evaluator {
+		scores := make(map[State]int)
+		return evaluatorFunc(func(s State, m Mask) int {
+			c := s.Canonicalize()
+			if score, ok := scores[c]; ok {
+				return score
+			}
+			layers, _ := Enumerate(context.Background(), c)
+			layers.Retrograde(context.Background(), func(states []State, v []int8) error {
+				scores[c] = int(v[0]) // the last layer emitted holds only c
+				return nil
+			})
+			return scores[c]
+		})
+	}, false},
+	{"alphabeta", func() Evaluator { return NewAlphaBeta(0) }, false},
+	{"aspiration", func() Evaluator {
+		e := NewAlphaBeta(2)
+		e.Ordering = true
+		return e
+	}, false},
+	{"pns", func() Evaluator { return NewPNS() }, true},
+}
+
+// referenceCase is a parsed reference position.
+type referenceCase struct {
+	s     State
+	m     Mask
+	score int
+	best  []int
+}
+
+// referenceCases parses the reference positions, skipping the opening
+// in short mode, where evaluators must solve nearly the whole game.
+func referenceCases(t *testing.T) []referenceCase {
+	var cases []referenceCase
+	for _, ref := range referencePositions {
+		s, err := ParsePosition(ref.position)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if testing.Short() && s.Turn() < 6 {
+			continue
+		}
+		cases = append(cases, referenceCase{s, s.Derive(), ref.score, ref.best})
+	}
+	return cases
+}
+
+// TestReferenceScores checks every evaluator against the reference
+// values in every orientation.
+func TestReferenceScores(t *testing.T) {
+	cases := referenceCases(t)
+	for _, ev := range referenceEvaluators {
+		t.Run(ev.name, func(t *testing.T) {
+			e := ev.new()
+			for _, c := range cases {
+				for tr := Transform(0); tr < 8 && (tr < 2 || !ev.slow); tr++ {
+					s, m := tr.Apply(c.s), tr.ApplyMask(c.m)
+					if got, want := e.Evaluate(s, m), c.score; got != want {
+						t.Errorf("%v: got %+d, want %+d", s, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestRetrogradeDisk solves retrograde into a disk table, checking the
+// reference values in the file and that reopening it resumes solved.
+func TestRetrogradeDisk(t *testing.T) {
+	if testing.Short() {
+		t.Skip("full solve")
+	}
+	path := filepath.Join(t.TempDir(), "table")
+	for run := 0; run < 2; run++ {
+		table, err := OpenDiskTable(path, 9_000_000, 8<<20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, st, err := SolveRetrogradeStore(context.Background(), table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != 2 || table.Len() != solvedStates {
+			t.Errorf("run %d: got value %+d with %d entries", run, value, table.Len())
+		}
+		if run == 1 && st.Nodes != 1 {
+			t.Errorf("resumed solve visited %d nodes", st.Nodes)
+		}
+		for _, c := range referenceCases(t) {
+			if got, ok := table.Get(c.s.Canonicalize()); !ok || int(got) != c.score {
+				t.Errorf("%v: got %+d (%v), want %+d", c.s, got, ok, c.score)
+			}
+		}
+		if err := table.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestReferenceMoves checks Suggest and the perfect engines against the
+// reference moves in every orientation.
+func TestReferenceMoves(t *testing.T) {
+	table := New()
+	engines := []struct {
+		name string
+		e    Engine
+	}{
+		{"perfect", Perfect{Table: table}},
+		{"perfect/random", Perfect{table, TieRandom{}}},
+		{"perfect/material", Perfect{table, new(TieMaterial)}},
+		{"varied", Varied{Table: table}},
+		{"epsilon", Epsilon{Table: table}},
+	}
+	for _, c := range referenceCases(t) {
+		for tr := Transform(0); tr < 8; tr++ {
+			s, m := tr.Apply(c.s), tr.ApplyMask(c.m)
+			var want []int
+			for _, i := range c.best {
+				if i > 0 {
+					i = tr.ApplySquare(i-1) + 1
+				}
+				want = append(want, i)
+			}
+			slices.Sort(want)
+
+			var got []int
+			switch {
+			case s.IsComplete(m):
+			case s.NoMoves(m):
+				got = []int{0}
+			default:
+				for _, i := range table.Suggest(s, m) {
+					got = append(got, i+1)
+				}
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("suggest: %v: got %v, want %v", s, got, want)
+			}
+			if s.IsComplete(m) {
+				continue
+			}
+			for _, e := range engines {
+				if i := e.e.Move(s, m) + 1; !slices.Contains(want, i) {
+					t.Errorf("%s: %v: played %d, want one of %v", e.name, s, i, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package bsquare
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Commentary describes a move played in a game: its score, the best
+// alternatives when it was not among them, and whether it changed the
+// game value. Scores are from the first player's perspective.
+func (v Variant) Commentary(g *Game, i int) string {
+	var buf strings.Builder
+	s, _ := g.Position()
+	before := v.value(g)
+	after := v.value(g.with(i))
+	if i < 0 {
+		fmt.Fprintf(&buf, "Player %v passes (%+d).", s.ToMove(), after)
+	} else {
+		fmt.Fprintf(&buf, "Player %v plays %d (%+d).", s.ToMove(), i+1, after)
+	}
+	if after == before {
+		return buf.String()
+	}
+	buf.WriteString(" Best was")
+	for _, j := range v.suggest(g) {
+		if j < 0 {
+			buf.WriteString(" pass")
+		} else {
+			fmt.Fprintf(&buf, " %d", j+1)
+		}
+	}
+	fmt.Fprintf(&buf, " (%+d). The game value changes from %v to %v.",
+		before, ScoreOutcome(before), ScoreOutcome(after))
+	return buf.String()
+}
+
+// value returns the minimax score of a game's current position, which
+// under voluntary passes depends on whether the last move passed.
+func (v Variant) value(g *Game) int {
+	s, m := g.Position()
+	n := len(g.Moves)
+	switch {
+	case g.Over():
+		return g.Score()
+	case v.Rules.VoluntaryPass && n > 0 && g.Moves[n-1] < 0:
+		score, _ := v.Table.passed(context.Background(), v.Rules, nil, nil, s, m)
+		return score
+	}
+	return v.Evaluate(s, m)
+}
+
+// suggest returns the perfect plays in a game, where -1 passes.
+func (v Variant) suggest(g *Game) []int {
+	s, m := g.Position()
+	n := len(g.Moves)
+	return v.perfect(s, m, n > 0 && g.Moves[n-1] < 0)
+}
+
+// with returns a copy of the game extended by a move, where -1 passes.
+func (g *Game) with(i int) *Game {
+	return &Game{Moves: append(g.Moves[:len(g.Moves):len(g.Moves)], i), Rules: g.Rules}
+}
+
+// variant returns a solved evaluator for some rules, using the solved
+// table for the standard rules.
+func variant(ctx context.Context, r Rules) (Variant, error) {
+	if r == (Rules{}) {
+		t, err := Solved(ctx)
+		return Variant{r, t}, err
+	}
+	v := NewVariant(r)
+	_, err := v.EvaluateContext(ctx, 0, r.Start())
+	return v, err
+}
+
+// Comment sets each node's comment to the commentary on the move leading
+// to it, after any existing comment.
+func (r *Record) Comment(v Variant) {
+	g := &Game{Rules: r.Game.Rules}
+	for n, i := range r.Game.Moves {
+		c := v.Commentary(g, i)
+		if old, ok := r.Comments[n+1]; ok {
+			c = old + "\n" + c
+		}
+		r.Comments[n+1] = c
+		g = g.with(i)
+	}
+}
+
+// replayMain implements the "replay" command, stepping through a game.
+func replayMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	comment := flags.Bool("comment", false, "comment on each move")
+	delay := flags.Duration("delay", 0, "pause between moves")
+	in := addInput(flags)
+	in.addRules(flags)
+	display := addTheme(flags)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if err := display.apply(); err != nil {
+		return err
+	}
+	rec, err := in.record(flags.Args())
+	if err != nil {
+		return err
+	}
+	var v Variant
+	if *comment {
+		if v, err = variant(ctx, rec.Game.Rules); err != nil {
+			return err
+		}
+	}
+
+	g := &Game{Rules: rec.Game.Rules}
+	for n := 0; ; n++ {
+		if c, ok := rec.Comments[n]; ok {
+			fmt.Println(c)
+		}
+		if n == len(rec.Game.Moves) {
+			break
+		}
+		i := rec.Game.Moves[n]
+		s, _ := g.Position()
+		if *comment {
+			fmt.Printf("%d. %s\n", n+1, v.Commentary(g, i))
+		} else if i < 0 {
+			fmt.Printf("%d. Player %v passes.\n", n+1, s.ToMove())
+		} else {
+			fmt.Printf("%d. Player %v plays %d.\n", n+1, s.ToMove(), i+1)
+		}
+		g = g.with(i)
+		s, m := g.Position()
+		theme.PrintBoard(os.Stdout, s, m, i)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(*delay):
+		}
+	}
+	if g.Over() {
+		fmt.Printf("Game over! Score: %d\n", g.Score())
+	}
+	return nil
+}
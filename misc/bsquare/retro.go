@@ -0,0 +1,247 @@
+package bsquare
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+	"slices"
+	"time"
+)
+
+// Layers holds the canonical positions reachable from a game state, one
+// sorted layer per turn starting from the state's turn. Every move
+// advances the turn, so each layer's successors lie in the next layer.
+type Layers [][]State
+
+// Enumerate finds the reachable positions breadth-first, deduplicated
+// by symmetry, stopping early with the context's error if it is
+// cancelled.
+func Enumerate(ctx context.Context, s State) (Layers, error) {
+	layers := Layers{{s.Canonicalize()}}
+	for {
+		next := make(map[State]struct{})
+		for k, s := range layers[len(layers)-1] {
+			if k&0xffff == 0 && ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			m := s.Derive()
+			if s.IsComplete(m) {
+				continue
+			} else if s.NoMoves(m) {
+				next[s.Pass().Canonicalize()] = struct{}{}
+				continue
+			}
+			for b := m.LegalBits(m.Turn()); b != 0; b &= b - 1 {
+				i := bits.TrailingZeros32(b)
+				next[s.Place(i).Canonicalize()] = struct{}{}
+			}
+		}
+		if len(next) == 0 {
+			return layers, nil
+		}
+		layer := make([]State, 0, len(next))
+		for s := range next {
+			layer = append(layer, s)
+		}
+		slices.Sort(layer)
+		layers = append(layers, layer)
+	}
+}
+
+// Len returns the total number of positions.
+func (l Layers) Len() int {
+	n := 0
+	for _, layer := range l {
+		n += len(layer)
+	}
+	return n
+}
+
+// Retrograde scores the layers backwards from the last, passing each
+// layer with its minimax scores to emit. It consumes the layers,
+// releasing each once the one before it is scored, so that at most two
+// layers of scores are held at once.
+func (l Layers) Retrograde(ctx context.Context, emit func(states []State, scores []int8) error) error {
+	_, err := l.RetrogradeStats(ctx, emit)
+	return err
+}
+
+// RetrogradeStats is Retrograde, also reporting the effort. Every scored
+// position is a node, and every successor looked up in the next layer a
+// hit. The table size is the most scores held at once.
+func (l Layers) RetrogradeStats(ctx context.Context, emit func(states []State, scores []int8) error) (SearchStats, error) {
+	st := SearchStats{MaxDepth: max(len(l)-1, 0)}
+	start := time.Now()
+	defer func() { st.Duration = time.Since(start) }()
+	var next []State
+	var nextScores []int8
+	lookup := func(s State) int {
+		st.Nodes++
+		st.Hits++
+		j, ok := slices.BinarySearch(next, s.Canonicalize())
+		if !ok {
+			panic(fmt.Sprintf("retrograde: successor %v not enumerated", s))
+		}
+		return int(nextScores[j])
+	}
+	for k := len(l) - 1; k >= 0; k-- {
+		states := l[k]
+		scores := make([]int8, len(states))
+		st.Nodes += len(states)
+		st.TableSize = max(st.TableSize, len(states)+len(next))
+		for j, s := range states {
+			if j&0xffff == 0 && ctx.Err() != nil {
+				return st, ctx.Err()
+			}
+			m := s.Derive()
+			switch {
+			case s.IsComplete(m):
+				scores[j] = int8(s.Score())
+			case s.NoMoves(m):
+				scores[j] = int8(lookup(s.Pass()))
+			default:
+				score := s.InitScore()
+				for b := m.LegalBits(m.Turn()); b != 0; b &= b - 1 {
+					i := bits.TrailingZeros32(b)
+					v := lookup(s.Place(i))
+					if s.ToMove() == SecondPlayer {
+						score = min(score, v)
+					} else {
+						score = max(score, v)
+					}
+				}
+				scores[j] = int8(score)
+			}
+		}
+		if err := emit(states, scores); err != nil {
+			return st, err
+		}
+		next, nextScores = states, scores
+		l[k] = nil
+	}
+	return st, nil
+}
+
+// SolveRetrograde fully solves a new minimax tree by enumerating the
+// reachable positions and scoring them backwards, rather than by
+// depth-first search.
+func SolveRetrograde(ctx context.Context) (Minimax, error) {
+	t, _, err := SolveRetrogradeStats(ctx)
+	return t, err
+}
+
+// SolveRetrogradeStats is SolveRetrograde, also reporting the effort of
+// the backward pass. The time includes the enumeration.
+func SolveRetrogradeStats(ctx context.Context) (Minimax, SearchStats, error) {
+	start := time.Now()
+	layers, err := Enumerate(ctx, 0)
+	if err != nil {
+		return nil, SearchStats{}, fmt.Errorf("enumeration stopped: %w", err)
+	}
+	t := make(Minimax, layers.Len())
+	st, err := layers.RetrogradeStats(ctx, func(states []State, scores []int8) error {
+		for j, s := range states {
+			t[s] = scores[j]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, st, fmt.Errorf("retrograde solve stopped: %w", err)
+	}
+	st.Duration = time.Since(start)
+	st.TableSize = len(t)
+	return t, st, nil
+}
+
+// SolveRetrogradeStore solves like SolveRetrogradeStats, but puts each
+// layer into a table store as it is scored, returning the game's value.
+// Only the enumerated positions and two layers of scores are held in
+// memory. A store already holding the root is taken as solved, and any
+// other is solved from the start, rescoring the entries it has.
+func SolveRetrogradeStore(ctx context.Context, t TableStore) (int, SearchStats, error) {
+	start := time.Now()
+	if score, ok := t.Get(0); ok {
+		return int(score), SearchStats{Nodes: 1, Hits: 1, Duration: time.Since(start), TableSize: storeLen(t)}, nil
+	}
+	layers, err := Enumerate(ctx, 0)
+	if err != nil {
+		return 0, SearchStats{}, fmt.Errorf("enumeration stopped: %w", err)
+	}
+	var value int
+	st, err := layers.RetrogradeStats(ctx, func(states []State, scores []int8) error {
+		for j, s := range states {
+			t.Put(s, scores[j])
+		}
+		value = int(scores[0]) // the root's layer comes last
+		return nil
+	})
+	if err != nil {
+		return 0, st, fmt.Errorf("retrograde solve stopped: %w", err)
+	}
+	st.Duration = time.Since(start)
+	st.TableSize = storeLen(t)
+	return value, st, nil
+}
+
+// GameCounts tallies games or endings by result.
+type GameCounts struct {
+	P1Wins, P2Wins, Ties uint64
+}
+
+// Total returns the number of games.
+func (c GameCounts) Total() uint64 {
+	return c.P1Wins + c.P2Wins + c.Ties
+}
+
+// add tallies n games ending in a complete state.
+func (c *GameCounts) add(s State, n uint64) {
+	switch score := s.Score(); {
+	case score > 0:
+		c.P1Wins += n
+	case score < 0:
+		c.P2Wins += n
+	default:
+		c.Ties += n
+	}
+}
+
+// CountGames counts the distinct move sequences from the first layer's
+// state to the end, by result, not merging games that differ only by
+// symmetry. The count of paths to each canonical position is carried
+// forward a layer at a time, since every orientation of a position has
+// the same number of moves into each successor's orientations.
+func (l Layers) CountGames(ctx context.Context) (GameCounts, error) {
+	var games GameCounts
+	paths := []uint64{1}
+	for k, states := range l {
+		var next []uint64
+		if k+1 < len(l) {
+			next = make([]uint64, len(l[k+1]))
+		}
+		forward := func(s State, n uint64) {
+			j, ok := slices.BinarySearch(l[k+1], s.Canonicalize())
+			if !ok {
+				panic(fmt.Sprintf("count: successor %v not enumerated", s))
+			}
+			next[j] += n
+		}
+		for j, s := range states {
+			if j&0xffff == 0 && ctx.Err() != nil {
+				return games, ctx.Err()
+			}
+			m := s.Derive()
+			switch {
+			case s.IsComplete(m):
+				games.add(s, paths[j])
+			case s.NoMoves(m):
+				forward(s.Pass(), paths[j])
+			default:
+				for b := m.LegalBits(m.Turn()); b != 0; b &= b - 1 {
+					forward(s.Place(bits.TrailingZeros32(b)), paths[j])
+				}
+			}
+		}
+		paths = next
+	}
+	return games, nil
+}
@@ -0,0 +1,264 @@
+package bsquare
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/bits"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Rules selects a rule variant. The zero value is the standard rules.
+type Rules struct {
+	// Supply limits the pieces available to each player, who must pass
+	// once out of pieces. Zero is unlimited.
+	Supply int
+
+	// CenterOpen permits the first player to open in the center.
+	CenterOpen bool
+
+	// Size is the board width, an odd number up to 5, with zero meaning
+	// 5. Smaller boards are the middle of the 5x5 board, so that they
+	// keep its symmetries and center.
+	Size int
+
+	// VoluntaryPass permits passing while moves remain, rather than only
+	// when forced, and the game then also ends when a position repeats,
+	// which only happens after two passes in a row.
+	VoluntaryPass bool
+
+	// RepetitionDraw scores a game ended by a repeated position as a draw
+	// rather than by its pieces. It only matters with VoluntaryPass.
+	RepetitionDraw bool
+}
+
+// String names the rules as in a game record's Rules header: "standard",
+// or the variations as space-separated options, e.g. "supply=11
+// center=open pass=voluntary repetition=draw".
+func (r Rules) String() string {
+	var opts []string
+	if r.Supply > 0 {
+		opts = append(opts, fmt.Sprintf("supply=%d", r.Supply))
+	}
+	if r.CenterOpen {
+		opts = append(opts, "center=open")
+	}
+	if r.Size != 0 && r.Size != 5 {
+		opts = append(opts, fmt.Sprintf("size=%d", r.Size))
+	}
+	if r.VoluntaryPass {
+		opts = append(opts, "pass=voluntary")
+	}
+	if r.RepetitionDraw {
+		opts = append(opts, "repetition=draw")
+	}
+	if len(opts) == 0 {
+		return "standard"
+	}
+	return strings.Join(opts, " ")
+}
+
+// ParseRules parses rules named by String. An empty name is the
+// standard rules.
+func ParseRules(name string) (Rules, error) {
+	var r Rules
+	if name == "" || name == "standard" {
+		return r, nil
+	}
+	for _, opt := range strings.Fields(name) {
+		switch opt {
+		case "center=ban", "pass=forced", "repetition=score":
+		case "center=open":
+			r.CenterOpen = true
+		case "pass=voluntary":
+			r.VoluntaryPass = true
+		case "repetition=draw":
+			r.RepetitionDraw = true
+		default:
+			key, value, _ := strings.Cut(opt, "=")
+			n, err := strconv.Atoi(value)
+			switch {
+			case key == "supply" && err == nil && n >= 0:
+				r.Supply = n
+			case key == "size" && err == nil && validSize(n):
+				r.Size = n
+				if n == 5 {
+					r.Size = 0
+				}
+			default:
+				return r, fmt.Errorf("unsupported rules: %q", opt)
+			}
+		}
+	}
+	return r, nil
+}
+
+// validSize indicates if a board width is supported: odd and at most 5.
+func validSize(n int) bool {
+	return n > 0 && n <= 5 && n%2 == 1
+}
+
+// Start returns the mask of the empty board, where the squares outside
+// a smaller board are blocked for both players.
+func (r Rules) Start() Mask {
+	var m Mask
+	if r.Size == 0 {
+		return m
+	}
+	lo, hi := (5-r.Size)/2, (5+r.Size)/2
+	for i := 0; i < 25; i++ {
+		if x, y := i%5, i/5; x < lo || x >= hi || y < lo || y >= hi {
+			m |= 1<<i | 1<<(i+25)
+		}
+	}
+	return m
+}
+
+// Valid indicates if a move is permitted.
+func (r Rules) Valid(m Mask, i int) bool {
+	return r.LegalBits(m)>>i&1 == 1
+}
+
+// LegalBits returns the squares legal for the player to move as a 25-bit
+// mask, ignoring the piece supply.
+func (r Rules) LegalBits(m Mask) uint32 {
+	if m.Turn() != 0 {
+		return m.LegalBits(m.Turn())
+	}
+	b := m.LegalBits(0)
+	if r.CenterOpen {
+		b = 0x1ffffff
+	}
+	return b &^ uint32(r.Start())
+}
+
+// blocked indicates if a player (0 or 1) has no moves under these rules,
+// regardless of whose turn it is.
+func (r Rules) blocked(s State, m Mask, who Player) bool {
+	if r.Supply > 0 && s.Pieces(who) >= r.Supply {
+		return true
+	}
+	return m>>who.offset()&0x1ffffff == 0x1ffffff
+}
+
+// NoMoves indicates if the current player has no moves, including an
+// opening player whose only square is a banned center.
+func (r Rules) NoMoves(s State, m Mask) bool {
+	return r.blocked(s, m, s.ToMove()) || r.LegalBits(m) == 0
+}
+
+// IsComplete indicates if the game has completed (no more moves).
+func (r Rules) IsComplete(s State, m Mask) bool {
+	return r.blocked(s, m, 0) && r.blocked(s, m, 1)
+}
+
+// Variant is a minimax evaluator for a rule variant, storing its own
+// explored game tree.
+type Variant struct {
+	Rules Rules
+	Table Minimax
+}
+
+// NewVariant returns an empty minimax tree for a rule variant.
+func NewVariant(r Rules) Variant {
+	return Variant{r, New()}
+}
+
+// Evaluate the minimax score at a game state.
+func (v Variant) Evaluate(s State, m Mask) int {
+	score, _ := v.Table.evaluate(context.Background(), v.Rules, nil, nil, s, m)
+	return score
+}
+
+// EvaluateContext is Evaluate, stopping early with the context's error
+// if it is cancelled.
+func (v Variant) EvaluateContext(ctx context.Context, s State, m Mask) (int, error) {
+	return v.Table.evaluate(ctx, v.Rules, nil, nil, s, m)
+}
+
+// after returns the minimax score after a move, where -1 passes, and
+// passed reports if the last move passed, so that under voluntary passes
+// passing again repeats the position and ends the game.
+func (v Variant) after(s State, m Mask, passed bool, i int) int {
+	switch {
+	case i >= 0:
+		return v.Evaluate(s.Place(i), m.Place(i))
+	case !v.Rules.VoluntaryPass:
+		return v.Evaluate(s.Pass(), m.Pass())
+	case passed && v.Rules.RepetitionDraw:
+		return 0
+	case passed:
+		return s.Score()
+	}
+	score, _ := v.Table.passed(context.Background(), v.Rules, nil, nil, s.Pass(), m.Pass())
+	return score
+}
+
+// perfect returns the perfect moves in square order, followed by -1
+// when passing is among them.
+func (v Variant) perfect(s State, m Mask, passed bool) []int {
+	var moves []int
+	if !v.Rules.NoMoves(s, m) {
+		for b := v.Rules.LegalBits(m); b != 0; b &= b - 1 {
+			moves = append(moves, bits.TrailingZeros32(b))
+		}
+	}
+	if v.Rules.VoluntaryPass || len(moves) == 0 {
+		moves = append(moves, -1)
+	}
+	var best []int
+	score := 0
+	for _, i := range moves {
+		tmp := v.after(s, m, passed, i) * s.ToMove().Sign()
+		if best == nil || tmp > score {
+			score, best = tmp, append(best[:0], i)
+		} else if tmp == score {
+			best = append(best, i)
+		}
+	}
+	return best
+}
+
+// supplyMain implements the "supply" command: supply [N...]
+//
+// Each piece supply limit is solved, reporting the game value and the
+// size of the game tree.
+func supplyMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("supply", flag.ContinueOnError)
+	format := addFormat(flags, "text")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	supplies := []int{0}
+	for i := 1; i <= 13; i++ {
+		supplies = append(supplies, i)
+	}
+	if flags.NArg() > 0 {
+		supplies = supplies[:0]
+		for _, arg := range flags.Args() {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid supply: %q", arg)
+			}
+			supplies = append(supplies, n)
+		}
+	}
+
+	r := new(Report)
+	tab := r.Table("Variants", "supply", "value", "states")
+	for _, n := range supplies {
+		v := NewVariant(Rules{Supply: n})
+		score, err := v.EvaluateContext(ctx, 0, 0)
+		if err != nil {
+			return err
+		}
+		name := strconv.Itoa(n)
+		if n == 0 {
+			name = "unlimited"
+		}
+		tab.Add(name, signed(score), len(v.Table))
+	}
+	return format.Write(os.Stdout, r)
+}
@@ -0,0 +1,155 @@
+package bsquare
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Sensitivity is the solution of one rule variant: its game value, the
+// value had the first player passed the opening move instead, and the
+// scores of the opening moves.
+type Sensitivity struct {
+	Rules     Rules
+	Value     int
+	Passed    int
+	Openings  []moveScore
+	States    int
+	Best      []int // perfect opening squares
+	Worst     int   // score of the worst opening, if any
+	Advantage int   // Value less Passed: what moving first is worth
+}
+
+// SolveSensitivity solves each rule variant, and again with the first
+// player passing the opening move, using up to jobs concurrent solvers.
+func SolveSensitivity(ctx context.Context, variants []Rules, jobs int) ([]Sensitivity, error) {
+	var configs []Config
+	for _, r := range variants {
+		configs = append(configs,
+			Config{Name: r.String(), Rules: r},
+			Config{Name: r.String() + " passed", Rules: r, Start: State(0).Pass()})
+	}
+	results, err := Sweep(ctx, configs, jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	sens := make([]Sensitivity, len(variants))
+	for k, r := range variants {
+		res, passed := results[2*k], results[2*k+1]
+		s := Sensitivity{
+			Rules:     r,
+			Value:     res.Value,
+			Passed:    passed.Value,
+			Openings:  res.Openings,
+			States:    res.States,
+			Advantage: res.Value - passed.Value,
+		}
+		for n, o := range res.Openings {
+			if o.Score == res.Value {
+				s.Best = append(s.Best, o.Square)
+			}
+			if n == 0 || o.Score < s.Worst {
+				s.Worst = o.Score
+			}
+		}
+		sens[k] = s
+	}
+	return sens, nil
+}
+
+// sensitivityMain implements the "sensitivity" command, solving a grid
+// of board sizes, center rules, and supplies, and comparing the values.
+func sensitivityMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("sensitivity", flag.ContinueOnError)
+	sizes := flags.String("size", "3,5", "comma-separated board sizes (1, 3, 5)")
+	center := flags.String("center", "ban,open", "comma-separated center rules (ban, open)")
+	supplies := flags.String("supply", "0", "comma-separated piece supplies (0: unlimited)")
+	jobs := flags.Int("j", runtime.NumCPU(), "concurrent solvers")
+	format := addFormat(flags, "text")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	// Columns are the board and center rule, rows are the supplies
+	type column struct {
+		size int
+		open bool
+	}
+	var columns []column
+	for _, arg := range strings.Split(*sizes, ",") {
+		n, err := strconv.Atoi(arg)
+		if err != nil || !validSize(n) {
+			return fmt.Errorf("invalid board size: %q", arg)
+		}
+		for _, c := range strings.Split(*center, ",") {
+			if c != "ban" && c != "open" {
+				return fmt.Errorf("invalid center rule: %q", c)
+			}
+			columns = append(columns, column{n, c == "open"})
+		}
+	}
+	var rows []int
+	for _, arg := range strings.Split(*supplies, ",") {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid supply: %q", arg)
+		}
+		rows = append(rows, n)
+	}
+	var variants []Rules
+	for _, n := range rows {
+		for _, c := range columns {
+			variants = append(variants, Rules{Supply: n, CenterOpen: c.open, Size: c.size})
+		}
+	}
+
+	sens, err := SolveSensitivity(ctx, variants, *jobs)
+	if err != nil {
+		return err
+	}
+
+	r := new(Report)
+	names := []string{"supply"}
+	for _, c := range columns {
+		center := "ban"
+		if c.open {
+			center = "open"
+		}
+		names = append(names, fmt.Sprintf("%dx%d %s", c.size, c.size, center))
+	}
+	values := r.Table("Values", names...)
+	advantages := r.Table("First-move advantage", names...)
+	for k, n := range rows {
+		supply := "unlimited"
+		if n > 0 {
+			supply = strconv.Itoa(n)
+		}
+		vrow, arow := []any{supply}, []any{supply}
+		for _, s := range sens[k*len(columns) : (k+1)*len(columns)] {
+			vrow = append(vrow, signed(s.Value))
+			arow = append(arow, signed(s.Advantage))
+		}
+		values.Add(vrow...)
+		advantages.Add(arow...)
+	}
+
+	openings := r.Table("Openings", "rules", "value", "passed", "worst", "best", "states")
+	for _, s := range sens {
+		best := make([]string, len(s.Best))
+		for k, i := range s.Best {
+			best[k] = strconv.Itoa(i)
+		}
+		var worst any
+		if len(s.Openings) > 0 {
+			worst = signed(s.Worst)
+		}
+		openings.Add(s.Rules.String(), signed(s.Value), signed(s.Passed),
+			worst, strings.Join(best, " "), s.States)
+	}
+	return format.Write(os.Stdout, r)
+}
@@ -0,0 +1,457 @@
+package bsquare
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HostedGame is a game in progress on the game server. Seats hold each
+// player's secret token, or are empty if not yet joined. An engine seat
+// is played by the server, and Effort sums its moves' search effort.
+type HostedGame struct {
+	State  State
+	Mask   Mask
+	Seats  [2]string
+	Engine [2]bool
+	Effort SearchStats
+}
+
+// ErrNotFound is returned by a Store for an unknown game ID.
+var ErrNotFound = errors.New("game not found")
+
+// Store persists hosted games by ID.
+type Store interface {
+	Load(id string) (HostedGame, error)
+	Save(id string, g HostedGame) error
+}
+
+// MemoryStore is a Store that keeps games in memory.
+type MemoryStore struct {
+	mu    sync.Mutex
+	games map[string]HostedGame
+}
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{games: make(map[string]HostedGame)}
+}
+
+// Load a game from memory.
+func (s *MemoryStore) Load(id string) (HostedGame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.games[id]
+	if !ok {
+		return g, ErrNotFound
+	}
+	return g, nil
+}
+
+// Save a game to memory.
+func (s *MemoryStore) Save(id string, g HostedGame) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.games[id] = g
+	return nil
+}
+
+// Server hosts live games over a JSON API, streaming updates with
+// server-sent events. The table must already be fully solved, and is
+// only used under the server's lock. Engine seats are played by Engine,
+// perfectly unless changed before serving.
+type Server struct {
+	Engine Engine
+
+	t     Minimax
+	store Store
+	mux   *http.ServeMux
+	mu    sync.Mutex
+	subs  map[string]map[chan gameEvent]bool
+}
+
+// NewServer returns a game server backed by the given store.
+func NewServer(t Minimax, store Store) *Server {
+	v := &Server{
+		Engine: Perfect{Table: t},
+		t:      t,
+		store:  store,
+		mux:    http.NewServeMux(),
+		subs:   make(map[string]map[chan gameEvent]bool),
+	}
+	v.mux.HandleFunc("POST /games", v.create)
+	v.mux.HandleFunc("POST /games/{id}/join", v.join)
+	v.mux.HandleFunc("POST /games/{id}/moves", v.move)
+	v.mux.HandleFunc("GET /games/{id}", v.get)
+	v.mux.HandleFunc("GET /games/{id}/events", v.events)
+	v.mux.HandleFunc("GET /positions/{id}", v.position)
+	return v
+}
+
+// gameEvent describes a game and the engine's commentary on it. Squares
+// are 1-indexed, players are 1 or 2, and 0 means none.
+type gameEvent struct {
+	ID       string       `json:"id,omitempty"`
+	Position string       `json:"position"`
+	Board    []int        `json:"board"`
+	Turn     int          `json:"turn"`
+	ToMove   int          `json:"to_move"`
+	Legal    []int        `json:"legal"`
+	Over     bool         `json:"over"`
+	Score    int          `json:"score"`
+	Best     []int        `json:"best"`
+	Joined   [2]bool      `json:"joined"`
+	Effort   *SearchStats `json:"engine_effort,omitempty"`
+}
+
+func (v *Server) event(id string, g HostedGame) gameEvent {
+	e := gameEvent{
+		ID:       id,
+		Position: EncodeID(g.State),
+		Board:    make([]int, 25),
+		Turn:     g.State.Turn(),
+		Over:     g.State.IsComplete(g.Mask),
+		Score:    v.t.Evaluate(g.State, g.Mask),
+		Legal:    []int{},
+		Best:     []int{},
+	}
+	for i := 0; i < 5*5; i++ {
+		if g.State>>i&1 == 1 {
+			e.Board[i] = 1
+		} else if g.State>>(i+25)&1 == 1 {
+			e.Board[i] = 2
+		}
+	}
+	if !e.Over {
+		e.ToMove = int(g.State.ToMove()) + 1
+		for i := 0; i < 5*5; i++ {
+			if g.Mask.Valid(i) {
+				e.Legal = append(e.Legal, i+1)
+			}
+		}
+		for _, i := range v.t.Suggest(g.State, g.Mask) {
+			e.Best = append(e.Best, i+1)
+		}
+	}
+	for i, seat := range g.Seats {
+		e.Joined[i] = seat != "" || g.Engine[i]
+	}
+	if g.Engine != [2]bool{} {
+		e.Effort = &g.Effort
+	}
+	return e
+}
+
+// advance plays engine moves and forced passes until a human is to move
+// or the game is over.
+func (v *Server) advance(g *HostedGame) {
+	for !g.State.IsComplete(g.Mask) {
+		if g.State.NoMoves(g.Mask) {
+			g.State, g.Mask = g.State.Pass(), g.Mask.Pass()
+		} else if g.Engine[g.State.ToMove()] {
+			i, st := MoveStats(v.Engine, g.State, g.Mask)
+			g.Effort.Add(st)
+			g.State, g.Mask = child(g.State, g.Mask, i)
+		} else {
+			return
+		}
+	}
+}
+
+func (v *Server) publish(id string, g HostedGame) {
+	e := v.event(id, g)
+	for c := range v.subs[id] {
+		select {
+		case c <- e:
+		default: // slow subscriber, drop the update
+		}
+	}
+}
+
+func token() string {
+	var buf [16]byte
+	rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusBadRequest
+	if errors.Is(err, ErrNotFound) {
+		code = http.StatusNotFound
+	}
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// ServeHTTP implements the game API:
+//
+//	POST /games                {"seat": 1|2, "engine": bool}
+//	POST /games/{id}/join
+//	POST /games/{id}/moves     {"token": "...", "square": 1-25}
+//	GET  /games/{id}
+//	GET  /games/{id}/events    (text/event-stream)
+//	GET  /positions/{id}       (analysis of a position ID)
+//
+// Creating or joining a game returns the seat's secret token.
+func (v *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mux.ServeHTTP(w, r)
+}
+
+func (v *Server) create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Seat   int  `json:"seat"`
+		Engine bool `json:"engine"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if req.Seat == 0 {
+		req.Seat = 1
+	}
+	if req.Seat != 1 && req.Seat != 2 {
+		writeError(w, fmt.Errorf("invalid seat: %d", req.Seat))
+		return
+	}
+
+	var g HostedGame
+	seat := req.Seat - 1
+	g.Seats[seat] = token()
+	g.Engine[1-seat] = req.Engine
+
+	// Engines need not be safe for concurrent use, so they play under
+	// the lock
+	id := token()[:12]
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.advance(&g)
+	if err := v.store.Save(id, g); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"id":    id,
+		"seat":  req.Seat,
+		"token": g.Seats[seat],
+	})
+}
+
+func (v *Server) join(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	g, err := v.store.Load(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	seat := -1
+	for i := range g.Seats {
+		if g.Seats[i] == "" && !g.Engine[i] {
+			seat = i
+			break
+		}
+	}
+	if seat < 0 {
+		writeError(w, errors.New("game is full"))
+		return
+	}
+	g.Seats[seat] = token()
+	if err := v.store.Save(id, g); err != nil {
+		writeError(w, err)
+		return
+	}
+	v.publish(id, g)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id":    id,
+		"seat":  seat + 1,
+		"token": g.Seats[seat],
+	})
+}
+
+func (v *Server) move(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token  string `json:"token"`
+		Square int    `json:"square"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	id := r.PathValue("id")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	g, err := v.store.Load(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if g.State.IsComplete(g.Mask) {
+		writeError(w, ErrGameOver)
+		return
+	}
+	seat := g.State.ToMove()
+	if req.Token == "" || req.Token != g.Seats[seat] {
+		writeJSON(w, http.StatusForbidden,
+			map[string]string{"error": "not your turn"})
+		return
+	}
+	i := req.Square - 1
+	s, err := g.State.PlaceChecked(i)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	g.State, g.Mask = s, g.Mask.Place(i)
+	v.advance(&g)
+	if err := v.store.Save(id, g); err != nil {
+		writeError(w, err)
+		return
+	}
+	v.publish(id, g)
+	writeJSON(w, http.StatusOK, v.event(id, g))
+}
+
+func (v *Server) get(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	g, err := v.store.Load(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v.event(id, g))
+}
+
+func (v *Server) position(w http.ResponseWriter, r *http.Request) {
+	s, err := DecodeID(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	g := HostedGame{State: s, Mask: s.Derive()}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	writeJSON(w, http.StatusOK, v.event("", g))
+}
+
+// Analysis returns an analysis handler over the server's table. It
+// shares the server's lock, since evaluating a position not yet in the
+// table records it.
+func (v *Server) Analysis() *Handler {
+	h := NewHandler(v.t)
+	h.mu = &v.mu
+	return h
+}
+
+func (v *Server) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	id := r.PathValue("id")
+	c := make(chan gameEvent, 16)
+	v.mu.Lock()
+	g, err := v.store.Load(id)
+	if err != nil {
+		v.mu.Unlock()
+		writeError(w, err)
+		return
+	}
+	if v.subs[id] == nil {
+		v.subs[id] = make(map[chan gameEvent]bool)
+	}
+	v.subs[id][c] = true
+	c <- v.event(id, g)
+	v.mu.Unlock()
+
+	defer func() {
+		v.mu.Lock()
+		delete(v.subs[id], c)
+		if len(v.subs[id]) == 0 {
+			delete(v.subs, id)
+		}
+		v.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	for {
+		select {
+		case e := <-c:
+			js, _ := json.Marshal(e)
+			fmt.Fprintf(w, "data: %s\n\n", js)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// serveMain implements the "serve" command, hosting games with analysis
+// under /analysis/ and the browser front-end at /.
+func serveMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", ":8080", "listen address")
+	name := flags.String("engine", "perfect", "engine for engine seats ("+engineUsage()+")")
+	opts := addEngineOptions(flags)
+	seed := addSeed(flags)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	t, err := Solved(ctx)
+	if err != nil {
+		return err
+	}
+	srv := NewServer(t, NewMemoryStore())
+	seed.apply()
+	if srv.Engine, err = NewEngine(*name, t, opts); err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", srv)
+	mux.Handle("/analysis/", http.StripPrefix("/analysis", srv.Analysis()))
+	mux.Handle("GET /{$}", webHandler())
+	return listenAndServe(ctx, *addr, mux)
+}
+
+// listenAndServe serves HTTP until the context is cancelled, then shuts
+// down gracefully. Request contexts derive from ctx, so long-lived
+// requests such as event streams end on cancellation.
+func listenAndServe(ctx context.Context, addr string, h http.Handler) error {
+	srv := &http.Server{
+		Addr:        addr,
+		Handler:     h,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	done := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		done <- srv.Shutdown(shutdown)
+	}()
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return <-done
+}
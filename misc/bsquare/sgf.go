@@ -0,0 +1,330 @@
+package bsquare
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Record is a game record with annotations, as stored in SGF. Nodes are
+// numbered by the moves played before them: node 0 is the root and node
+// n follows the nth move, with forced passes counted as moves.
+type Record struct {
+	Headers  Headers
+	Game     Game
+	Comments map[int]string // comment by node
+	Values   map[int]int    // engine score by node, first player's perspective
+}
+
+// NewRecord returns an unannotated record of a game.
+func NewRecord(g *Game) *Record {
+	return &Record{
+		Game:     Game{Moves: append([]int(nil), g.Moves...), Rules: g.Rules},
+		Comments: make(map[int]string),
+		Values:   make(map[int]int),
+	}
+}
+
+// Annotate records the minimax score at every node.
+func (r *Record) Annotate(v Variant) {
+	g := &Game{Rules: r.Game.Rules}
+	r.Values[0] = v.value(g)
+	for n, i := range r.Game.Moves {
+		g = g.with(i)
+		r.Values[n+1] = v.value(g)
+	}
+}
+
+// WriteSGF writes the record in an SGF dialect: the first player is
+// black (B) and the second white (W), squares are column-row letter
+// pairs from "aa" at the top left, an empty value passes, and V holds
+// the engine score. Headers map to EV, DT, PB, PW, RU, and RE. The game
+// has no assigned GM number.
+func (r *Record) WriteSGF(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	h := r.Headers
+	rules := h.Rules
+	if rules == "" {
+		rules = r.Game.Rules.String()
+	}
+	fmt.Fprintf(buf, "(;FF[4]CA[UTF-8]AP[bsquare]SZ[5]RU[%s]", sgfText(rules))
+	for _, p := range []struct{ id, value string }{
+		{"EV", h.Event},
+		{"DT", h.Date},
+		{"PB", h.Players[0]},
+		{"PW", h.Players[1]},
+		{"RE", sgfResult(h.Result)},
+	} {
+		if p.value != "" {
+			fmt.Fprintf(buf, "%s[%s]", p.id, sgfText(p.value))
+		}
+	}
+	r.writeNode(buf, 0)
+	for n, i := range r.Game.Moves {
+		color := "B"
+		if TurnPlayer(n) == SecondPlayer {
+			color = "W"
+		}
+		fmt.Fprintf(buf, "\n;%s[%s]", color, sgfPoint(i))
+		r.writeNode(buf, n+1)
+	}
+	buf.WriteString(")\n")
+	return buf.Flush()
+}
+
+func (r *Record) writeNode(w *bufio.Writer, n int) {
+	if v, ok := r.Values[n]; ok {
+		fmt.Fprintf(w, "V[%d]", v)
+	}
+	if c, ok := r.Comments[n]; ok {
+		fmt.Fprintf(w, "C[%s]", sgfText(c))
+	}
+}
+
+// sgfText escapes a property value.
+func sgfText(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, "]", `\]`)
+}
+
+// sgfPoint encodes a square, or an empty string for a pass.
+func sgfPoint(i int) string {
+	if i < 0 {
+		return ""
+	}
+	return string([]byte{'a' + byte(i%5), 'a' + byte(i/5)})
+}
+
+// sgfNode is a parsed SGF node's properties.
+type sgfNode map[string][]string
+
+// ParseSGF reads the main line of an SGF record written by WriteSGF or a
+// compatible tool. Forced passes may be omitted, and unknown properties
+// are ignored.
+func ParseSGF(r io.Reader) (*Record, error) {
+	nodes, err := parseSGFNodes(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, errors.New("sgf: empty record")
+	}
+	root := nodes[0]
+	if sz := root["SZ"]; sz != nil && sz[0] != "5" {
+		return nil, fmt.Errorf("sgf: unsupported board size: %s", sz[0])
+	}
+	g := new(Game)
+	if ru := root["RU"]; ru != nil {
+		if g.Rules, err = ParseRules(ru[0]); err != nil {
+			return nil, fmt.Errorf("sgf: %v", err)
+		}
+	}
+
+	rec := NewRecord(g)
+	for id, field := range map[string]*string{
+		"EV": &rec.Headers.Event,
+		"DT": &rec.Headers.Date,
+		"PB": &rec.Headers.Players[0],
+		"PW": &rec.Headers.Players[1],
+		"RU": &rec.Headers.Rules,
+	} {
+		if v := root[id]; v != nil {
+			*field = v[0]
+		}
+	}
+	if re := root["RE"]; re != nil {
+		rec.Headers.Result = parseSGFResult(re[0])
+	}
+	for k, node := range nodes {
+		if k > 0 || node["B"] != nil || node["W"] != nil {
+			if err := rec.play(node); err != nil {
+				return nil, fmt.Errorf("sgf: node %d: %v", k, err)
+			}
+		}
+		n := len(rec.Game.Moves)
+		if v := node["V"]; v != nil {
+			score, err := strconv.ParseFloat(v[0], 64)
+			if err != nil {
+				return nil, fmt.Errorf("sgf: node %d: invalid value: %q", k, v[0])
+			}
+			rec.Values[n] = int(score)
+		}
+		if c := node["C"]; c != nil {
+			rec.Comments[n] = c[0]
+		}
+	}
+	return rec, nil
+}
+
+// play applies a node's move, if it has one.
+func (r *Record) play(node sgfNode) error {
+	color, value := "B", node["B"]
+	if value == nil {
+		color, value = "W", node["W"]
+	}
+	if value == nil {
+		return nil
+	}
+	g := &r.Game
+	if g.Over() {
+		return ErrGameOver
+	}
+	s, m := g.Position()
+	want := FirstPlayer
+	if color == "W" {
+		want = SecondPlayer
+	}
+	if s.ToMove() != want && g.Rules.NoMoves(s, m) {
+		g.Moves = append(g.Moves, -1) // omitted forced pass
+		s = s.Pass()
+	}
+	if s.ToMove() != want {
+		return fmt.Errorf("player %v to move", s.ToMove())
+	}
+
+	p := value[0]
+	if p == "" || p == "tt" {
+		return g.Pass()
+	}
+	if len(p) != 2 || p[0] < 'a' || p[0] > 'e' || p[1] < 'a' || p[1] > 'e' {
+		return fmt.Errorf("%w: %q", ErrInvalidSquare, p)
+	}
+	return g.Play(int(p[1]-'a')*5 + int(p[0]-'a'))
+}
+
+// parseSGFNodes returns the nodes of the first game tree in order,
+// following the first variation at each branch.
+func parseSGFNodes(r *bufio.Reader) ([]sgfNode, error) {
+	var nodes []sgfNode
+	var node sgfNode
+	depth := 0
+	done := false
+	for {
+		c, err := r.ReadByte()
+		if err == io.EOF {
+			if depth > 0 {
+				return nil, errors.New("sgf: unterminated game tree")
+			}
+			return nodes, nil
+		} else if err != nil {
+			return nil, err
+		}
+		switch {
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth == 0 {
+				return nil, errors.New("sgf: unbalanced parenthesis")
+			}
+			depth--
+			done = true // the main line ends at the first close
+			if depth == 0 {
+				return nodes, nil
+			}
+		case c == ';':
+			if depth == 0 {
+				return nil, errors.New("sgf: node outside game tree")
+			}
+			node = make(sgfNode)
+			if !done {
+				nodes = append(nodes, node)
+			}
+		case c >= 'A' && c <= 'Z':
+			if node == nil {
+				return nil, errors.New("sgf: property outside node")
+			}
+			ident := []byte{c}
+			for {
+				c, err = r.ReadByte()
+				if err != nil {
+					return nil, unexpected(err)
+				}
+				if c < 'A' || c > 'Z' {
+					break
+				}
+				ident = append(ident, c)
+			}
+			r.UnreadByte()
+			values, err := parseSGFValues(r)
+			if err != nil {
+				return nil, err
+			}
+			node[string(ident)] = values
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		default:
+			return nil, fmt.Errorf("sgf: unexpected %q", c)
+		}
+	}
+}
+
+// parseSGFValues reads a property's bracketed values.
+func parseSGFValues(r *bufio.Reader) ([]string, error) {
+	var values []string
+	for {
+		c, err := r.ReadByte()
+		for err == nil && (c == ' ' || c == '\t' || c == '\r' || c == '\n') {
+			c, err = r.ReadByte()
+		}
+		if err != nil || c != '[' {
+			if err == nil {
+				r.UnreadByte()
+			}
+			if len(values) == 0 {
+				return nil, errors.New("sgf: property without value")
+			}
+			return values, nil
+		}
+		var value strings.Builder
+		for {
+			c, err := r.ReadByte()
+			if err != nil {
+				return nil, unexpected(err)
+			}
+			if c == ']' {
+				break
+			}
+			if c == '\\' {
+				if c, err = r.ReadByte(); err != nil {
+					return nil, unexpected(err)
+				}
+			}
+			value.WriteByte(c)
+		}
+		values = append(values, value.String())
+	}
+}
+
+// sgfMain implements the "sgf" command, writing a game as SGF.
+func sgfMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("sgf", flag.ContinueOnError)
+	eval := flags.Bool("eval", false, "annotate each position with its score")
+	comment := flags.Bool("comment", false, "comment on each move")
+	in := addInput(flags)
+	in.addRules(flags)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	rec, err := in.record(flags.Args())
+	if err != nil {
+		return err
+	}
+	if *eval || *comment {
+		v, err := variant(ctx, rec.Game.Rules)
+		if err != nil {
+			return err
+		}
+		if *eval {
+			rec.Annotate(v)
+		}
+		if *comment {
+			rec.Comment(v)
+		}
+	}
+	return rec.WriteSGF(os.Stdout)
+}
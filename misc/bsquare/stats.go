@@ -0,0 +1,159 @@
+package bsquare
+
+import (
+	"context"
+	"time"
+)
+
+// SearchStats describes the effort of an evaluation. Nodes counts every
+// position visited, including the Hits resolved directly by a table.
+type SearchStats struct {
+	Nodes     int           `json:"nodes"`
+	Hits      int           `json:"hits"`
+	MaxDepth  int           `json:"max_depth"` // deepest ply below the root
+	Duration  time.Duration `json:"duration_ns"`
+	TableSize int           `json:"table_size"` // peak table entries
+}
+
+// Measured is an evaluator that reports the effort of each evaluation.
+type Measured interface {
+	Evaluator
+	EvaluateStats(s State, m Mask) (int, SearchStats)
+}
+
+// MeasuredEngine is an engine that reports the effort of each move.
+type MeasuredEngine interface {
+	Engine
+	MoveStats(s State, m Mask) (int, SearchStats)
+}
+
+// MoveStats plays a move with any engine, reporting the effort of a
+// MeasuredEngine and only the time taken by any other.
+func MoveStats(e Engine, s State, m Mask) (int, SearchStats) {
+	if me, ok := e.(MeasuredEngine); ok {
+		return me.MoveStats(s, m)
+	}
+	start := time.Now()
+	i := e.Move(s, m)
+	return i, SearchStats{Duration: time.Since(start)}
+}
+
+// Tally is an engine summing the effort of another's moves, as reported
+// by MoveStats.
+type Tally struct {
+	Engine Engine
+	Moves  int
+	Stats  SearchStats
+}
+
+// Move plays the engine's move, adding its effort to the tally.
+func (t *Tally) Move(s State, m Mask) int {
+	i, st := MoveStats(t.Engine, s, m)
+	t.Moves++
+	t.Stats.Add(st)
+	return i
+}
+
+// measure runs a search from s that counts into its statistics, then
+// fills in the duration and the depth below s.
+func measure(s State, search func(*SearchStats)) SearchStats {
+	st := SearchStats{MaxDepth: s.Turn()}
+	start := time.Now()
+	search(&st)
+	st.Duration = time.Since(start)
+	st.MaxDepth -= s.Turn()
+	return st
+}
+
+// Add accumulates the statistics of another evaluation.
+func (st *SearchStats) Add(o SearchStats) {
+	st.Nodes += o.Nodes
+	st.Hits += o.Hits
+	st.MaxDepth = max(st.MaxDepth, o.MaxDepth)
+	st.Duration += o.Duration
+	st.TableSize = max(st.TableSize, o.TableSize)
+}
+
+// AddTo adds the statistics to a report as fields.
+func (st SearchStats) AddTo(r *Report) {
+	r.Add("Nodes", st.Nodes)
+	r.Add("Hits", st.Hits)
+	r.Add("Max depth", st.MaxDepth)
+	r.Add("Time", st.Duration)
+	r.Add("Table size", st.TableSize)
+}
+
+// EvaluateStats is Evaluate, also reporting the search effort. Positions
+// already in the table count as hits.
+func (t Minimax) EvaluateStats(s State, m Mask) (int, SearchStats) {
+	score, st, _ := t.evaluateStats(context.Background(), s, m)
+	return score, st
+}
+
+// evaluateStats is EvaluateStats, stopping early with the context's
+// error if it is cancelled.
+func (t Minimax) evaluateStats(ctx context.Context, s State, m Mask) (int, SearchStats, error) {
+	st := SearchStats{MaxDepth: s.Turn()}
+	start := time.Now()
+	score, err := t.evaluate(ctx, Rules{}, nil, &st, s, m)
+	st.Duration = time.Since(start)
+	st.MaxDepth -= s.Turn()
+	st.TableSize = len(t)
+	return score, st, err
+}
+
+// EvaluateStats is Evaluate, also reporting the search effort.
+func (v Variant) EvaluateStats(s State, m Mask) (int, SearchStats) {
+	st := SearchStats{MaxDepth: s.Turn()}
+	start := time.Now()
+	score, _ := v.Table.evaluate(context.Background(), v.Rules, nil, &st, s, m)
+	st.Duration = time.Since(start)
+	st.MaxDepth -= s.Turn()
+	st.TableSize = len(v.Table)
+	return score, st
+}
+
+// EvaluateStats is Evaluate, also reporting the search effort, with
+// nodes and hits counted across any re-searches.
+func (e *AlphaBeta) EvaluateStats(s State, m Mask) (int, SearchStats) {
+	nodes, hits := e.Nodes, e.Hits
+	e.deepest = s.Turn()
+	start := time.Now()
+	score := e.Evaluate(s, m)
+	return score, SearchStats{
+		Nodes:     e.Nodes - nodes,
+		Hits:      e.Hits - hits,
+		MaxDepth:  e.deepest - s.Turn(),
+		Duration:  time.Since(start),
+		TableSize: e.table.len(),
+	}
+}
+
+// EvaluateStats is Evaluate, also reporting the search effort summed
+// over every proof. Nodes counts expanded positions.
+func (p *PNS) EvaluateStats(s State, m Mask) (int, SearchStats) {
+	p.begin(s)
+	start := time.Now()
+	score := p.Evaluate(s, m)
+	return score, p.end(s, start)
+}
+
+// WinStats is Win, also reporting the search effort.
+func (p *PNS) WinStats(s State, m Mask) (bool, SearchStats) {
+	p.begin(s)
+	start := time.Now()
+	win := p.Win(s, m)
+	return win, p.end(s, start)
+}
+
+func (p *PNS) begin(s State) {
+	p.stats = SearchStats{Nodes: p.Nodes, MaxDepth: s.Turn()}
+}
+
+func (p *PNS) end(s State, start time.Time) SearchStats {
+	st := p.stats
+	st.Nodes = p.Nodes - st.Nodes
+	st.MaxDepth -= s.Turn()
+	st.Duration = time.Since(start)
+	return st
+}
@@ -0,0 +1,149 @@
+package bsquare
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/bits"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Config is one configuration of a rule sweep: a rule variant and the
+// starting position, which may carry a handicap.
+type Config struct {
+	Name  string
+	Rules Rules
+	Start State
+}
+
+// Result is the solution of a Config.
+type Result struct {
+	Config
+	Value    int         // minimax score at the starting position
+	States   int         // size of the explored game tree
+	Openings []moveScore // score after each legal first move
+}
+
+// Sweep solves each configuration using up to jobs concurrent solvers,
+// returning results in configuration order, or the context's error if it
+// is cancelled. Each solver holds a complete
+// game tree, so memory use scales with jobs.
+func Sweep(ctx context.Context, configs []Config, jobs int) ([]Result, error) {
+	results := make([]Result, len(configs))
+	sem := make(chan struct{}, max(jobs, 1))
+	var wg sync.WaitGroup
+	for i, c := range configs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			v := NewVariant(c.Rules)
+			s, m := c.Start, c.Rules.Start()|c.Start.Derive()
+			score, err := v.EvaluateContext(ctx, s, m)
+			if err != nil {
+				return
+			}
+			res := Result{Config: c, Value: score, States: len(v.Table)}
+			if !c.Rules.NoMoves(s, m) {
+				for b := c.Rules.LegalBits(m); b != 0; b &= b - 1 {
+					j := bits.TrailingZeros32(b)
+					res.Openings = append(res.Openings, moveScore{j + 1, v.Evaluate(s.Place(j), m.Place(j))})
+				}
+			}
+			results[i] = res
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// sweepMain implements the "sweep" command, solving every combination of
+// the given board sizes, supplies, center rules, pass rules, and starting
+// positions.
+func sweepMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("sweep", flag.ContinueOnError)
+	sizes := flags.String("size", "5", "comma-separated board sizes (1, 3, 5)")
+	supplies := flags.String("supply", "0", "comma-separated piece supplies (0: unlimited)")
+	center := flags.String("center", "ban", "comma-separated center rules (ban, open)")
+	passes := flags.String("pass", "forced", "comma-separated pass rules (forced, voluntary, draw)")
+	starts := flags.String("start", "", "comma-separated handicap positions")
+	jobs := flags.Int("j", runtime.NumCPU(), "concurrent solvers")
+	format := addFormat(flags, "text")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	var configs []Config
+	positions := []string{""}
+	if *starts != "" {
+		positions = strings.Split(*starts, ",")
+	}
+	for _, arg := range strings.Split(*sizes, ",") {
+		size, err := strconv.Atoi(arg)
+		if err != nil || !validSize(size) {
+			return fmt.Errorf("invalid board size: %q", arg)
+		}
+		for _, sup := range strings.Split(*supplies, ",") {
+			n, err := strconv.Atoi(sup)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid supply: %q", sup)
+			}
+			for _, c := range strings.Split(*center, ",") {
+				if c != "ban" && c != "open" {
+					return fmt.Errorf("invalid center rule: %q", c)
+				}
+				for _, pass := range strings.Split(*passes, ",") {
+					if pass != "forced" && pass != "voluntary" && pass != "draw" {
+						return fmt.Errorf("invalid pass rule: %q", pass)
+					}
+					for _, p := range positions {
+						rules := Rules{
+							Size:           size % 5, // 0 is the full board
+							Supply:         n,
+							CenterOpen:     c == "open",
+							VoluntaryPass:  pass != "forced",
+							RepetitionDraw: pass == "draw",
+						}
+						var start State
+						if p != "" {
+							var err error
+							if start, err = ParsePosition(p); err != nil {
+								return err
+							}
+						}
+						if uint32(start|start>>25)&uint32(rules.Start()) != 0 {
+							return fmt.Errorf("start %q is off the %dx%d board", p, size, size)
+						}
+						name := fmt.Sprintf("size=%d supply=%d center=%s", size, n, c)
+						if pass != "forced" {
+							name += " pass=" + pass
+						}
+						if p != "" {
+							name += " start=" + p
+						}
+						configs = append(configs, Config{Name: name, Rules: rules, Start: start})
+					}
+				}
+			}
+		}
+	}
+
+	results, err := Sweep(ctx, configs, *jobs)
+	if err != nil {
+		return err
+	}
+	r := new(Report)
+	tab := r.Table("Results", "value", "states", "config")
+	for _, res := range results {
+		tab.Add(signed(res.Value), res.States, res.Name)
+	}
+	return format.Write(os.Stdout, r)
+}
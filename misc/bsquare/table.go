@@ -0,0 +1,237 @@
+package bsquare
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+)
+
+// WriteTo writes the table sorted by canonical state, 9 bytes per
+// entry: the little endian 64-bit state and the 8-bit score.
+func (t Minimax) WriteTo(w io.Writer) (int64, error) {
+	states := make([]State, 0, len(t))
+	for s := range t {
+		states = append(states, s)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i] < states[j] })
+
+	buf := bufio.NewWriter(w)
+	for _, s := range states {
+		var entry [9]byte
+		binary.LittleEndian.PutUint64(entry[:], uint64(s))
+		entry[8] = byte(t[s])
+		buf.Write(entry[:])
+	}
+	return 9 * int64(len(states)), buf.Flush()
+}
+
+// ReadTable reads a table written by WriteTo.
+func ReadTable(r io.Reader) (Minimax, error) {
+	buf := bufio.NewReader(r)
+	t := New()
+	for {
+		var entry [9]byte
+		if _, err := io.ReadFull(buf, entry[:]); err == io.EOF {
+			return t, nil
+		} else if err != nil {
+			return nil, err
+		}
+		t[State(binary.LittleEndian.Uint64(entry[:]))] = int8(entry[8])
+	}
+}
+
+var compactMagic = [4]byte{'B', 'S', 'Q', 'T'}
+
+// TableWriter streams a table in the compact format: a magic number,
+// then runs of entries sharing a score, each a uvarint count, the score
+// byte, and the count states as uvarint deltas from the previous state.
+// A zero count ends the table. States must be written in ascending order.
+type TableWriter struct {
+	buf    *bufio.Writer
+	prev   State // last state written out
+	score  int8
+	run    []State
+	header bool
+	count  int
+}
+
+// NewTableWriter returns a compact table writer.
+func NewTableWriter(w io.Writer) *TableWriter {
+	return &TableWriter{buf: bufio.NewWriter(w)}
+}
+
+// Write adds an entry to the table.
+func (w *TableWriter) Write(s State, score int8) error {
+	if !w.header {
+		w.buf.Write(compactMagic[:])
+		w.header = true
+	}
+	if w.count > 0 && s <= w.last() {
+		return errors.New("table states out of order")
+	}
+	if len(w.run) > 0 && score != w.score {
+		w.flush()
+	}
+	w.score = score
+	w.run = append(w.run, s)
+	w.count++
+	return nil
+}
+
+// last returns the most recently added state.
+func (w *TableWriter) last() State {
+	if len(w.run) > 0 {
+		return w.run[len(w.run)-1]
+	}
+	return w.prev
+}
+
+func (w *TableWriter) flush() {
+	w.buf.Write(binary.AppendUvarint(nil, uint64(len(w.run))))
+	w.buf.WriteByte(byte(w.score))
+	for _, s := range w.run {
+		w.buf.Write(binary.AppendUvarint(nil, uint64(s-w.prev)))
+		w.prev = s
+	}
+	w.run = w.run[:0]
+}
+
+// Close writes the end of the table and flushes it. It does not close
+// the underlying writer.
+func (w *TableWriter) Close() error {
+	if !w.header {
+		w.buf.Write(compactMagic[:])
+		w.header = true
+	}
+	if len(w.run) > 0 {
+		w.flush()
+	}
+	w.buf.WriteByte(0)
+	return w.buf.Flush()
+}
+
+// TableReader streams entries from a compact table.
+type TableReader struct {
+	buf    *bufio.Reader
+	prev   State
+	score  int8
+	run    uint64
+	header bool
+	done   bool
+}
+
+// NewTableReader returns a compact table reader.
+func NewTableReader(r io.Reader) *TableReader {
+	return &TableReader{buf: bufio.NewReader(r)}
+}
+
+// Next returns the next entry, or io.EOF at the end of the table.
+func (r *TableReader) Next() (State, int8, error) {
+	if !r.header {
+		var magic [4]byte
+		if _, err := io.ReadFull(r.buf, magic[:]); err != nil {
+			return 0, 0, err
+		}
+		if magic != compactMagic {
+			return 0, 0, errors.New("not a compact table")
+		}
+		r.header = true
+	}
+	if r.done {
+		return 0, 0, io.EOF
+	}
+	if r.run == 0 {
+		n, err := binary.ReadUvarint(r.buf)
+		if err != nil {
+			return 0, 0, unexpected(err)
+		}
+		if n == 0 {
+			r.done = true
+			return 0, 0, io.EOF
+		}
+		score, err := r.buf.ReadByte()
+		if err != nil {
+			return 0, 0, unexpected(err)
+		}
+		r.run, r.score = n, int8(score)
+	}
+	delta, err := binary.ReadUvarint(r.buf)
+	if err != nil {
+		return 0, 0, unexpected(err)
+	}
+	r.run--
+	r.prev += State(delta)
+	return r.prev, r.score, nil
+}
+
+// unexpected converts a mid-table io.EOF to io.ErrUnexpectedEOF.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// WriteCompact writes the table in the compact format.
+func (t Minimax) WriteCompact(w io.Writer) error {
+	states := make([]State, 0, len(t))
+	for s := range t {
+		states = append(states, s)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i] < states[j] })
+	tw := NewTableWriter(w)
+	for _, s := range states {
+		if err := tw.Write(s, t[s]); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// ReadCompact reads a table in the compact format.
+func ReadCompact(r io.Reader) (Minimax, error) {
+	t := New()
+	tr := NewTableReader(r)
+	for {
+		s, score, err := tr.Next()
+		if err == io.EOF {
+			return t, nil
+		} else if err != nil {
+			return nil, err
+		}
+		t[s] = score
+	}
+}
+
+// TableStats describes the size of a table.
+type TableStats struct {
+	Entries int
+	Bytes   int64 // approximate memory use
+}
+
+// tableEntryBytes approximates the memory per map entry: a 16-byte
+// slot for the padded key and value plus a control byte, with the
+// slots of a large map about half full on average.
+const tableEntryBytes = 34
+
+// Stats reports the size of the table.
+func (t Minimax) Stats() TableStats {
+	return TableStats{len(t), int64(len(t)) * tableEntryBytes}
+}
+
+// Prune returns a copy of the table without the entries for which drop
+// is true, such as positions past some turn. Go maps never shrink, so
+// deleting in place would free no memory. Evaluating a dropped position
+// solves and records it again, so the copy is only as read-only as the
+// positions it is asked about.
+func (t Minimax) Prune(drop func(s State, score int8) bool) Minimax {
+	p := New()
+	for s, score := range t {
+		if !drop(s, score) {
+			p[s] = score
+		}
+	}
+	return p
+}
@@ -0,0 +1,97 @@
+package bsquare
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"maps"
+	"testing"
+)
+
+// testTable solves a midgame position, a table of about 24,000 entries.
+func testTable(t testing.TB) Minimax {
+	s, err := ParsePosition("...o./.o.../..o../xx.x./.....:6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := New()
+	table.Evaluate(s, s.Derive())
+	return table
+}
+
+// tableFormats lists the table encodings.
+var tableFormats = []struct {
+	name  string
+	write func(Minimax, io.Writer) error
+	read  func(io.Reader) (Minimax, error)
+}{
+	{"raw", func(t Minimax, w io.Writer) error { _, err := t.WriteTo(w); return err }, ReadTable},
+	{"compact", Minimax.WriteCompact, ReadCompact},
+}
+
+// TestTableFormats round trips a table through each encoding, and
+// checks that the compact encoding is the smaller.
+func TestTableFormats(t *testing.T) {
+	table := testTable(t)
+	sizes := make(map[string]int)
+	for _, f := range tableFormats {
+		var buf bytes.Buffer
+		if err := f.write(table, &buf); err != nil {
+			t.Fatalf("%s: %v", f.name, err)
+		}
+		sizes[f.name] = buf.Len()
+		got, err := f.read(&buf)
+		if err != nil {
+			t.Fatalf("%s: %v", f.name, err)
+		}
+		if !maps.Equal(got, table) {
+			t.Errorf("%s: read %d entries, not the %d written", f.name, len(got), len(table))
+		}
+	}
+	if sizes["compact"] >= sizes["raw"] {
+		t.Errorf("compact %d bytes, raw %d bytes", sizes["compact"], sizes["raw"])
+	}
+}
+
+// TestCompactErrors checks the compact writer and reader reject bad
+// input.
+func TestCompactErrors(t *testing.T) {
+	w := NewTableWriter(io.Discard)
+	w.Write(2, 0)
+	if err := w.Write(1, 0); err == nil {
+		t.Error("out of order write succeeded")
+	}
+
+	var buf bytes.Buffer
+	if err := testTable(t).WriteCompact(&buf); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ReadCompact(bytes.NewReader(buf.Bytes()[:buf.Len()/2]))
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated table: got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	if _, err := ReadCompact(bytes.NewReader(make([]byte, 9))); err == nil {
+		t.Error("raw table read as compact")
+	}
+}
+
+// BenchmarkTableFormats compares the speed and size of the encodings.
+func BenchmarkTableFormats(b *testing.B) {
+	table := testTable(b)
+	for _, f := range tableFormats {
+		var buf bytes.Buffer
+		f.write(table, &buf)
+		encoded := buf.Bytes()
+		b.Run(f.name+"/write", func(b *testing.B) {
+			for b.Loop() {
+				f.write(table, io.Discard)
+			}
+			b.ReportMetric(float64(len(encoded))/float64(len(table)), "bytes/entry")
+		})
+		b.Run(f.name+"/read", func(b *testing.B) {
+			for b.Loop() {
+				f.read(bytes.NewReader(encoded))
+			}
+		})
+	}
+}
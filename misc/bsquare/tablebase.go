@@ -0,0 +1,29 @@
+//go:build tablebase
+
+package bsquare
+
+import (
+	"compress/gzip"
+	"embed"
+)
+
+//go:embed tablebase
+var tablebaseFS embed.FS
+
+func init() {
+	tablebase = loadTablebase
+}
+
+// loadTablebase reads the embedded gzip-compressed compact table.
+func loadTablebase() (Minimax, error) {
+	f, err := tablebaseFS.Open("tablebase/table.gz")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	z, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	return ReadCompact(z)
+}
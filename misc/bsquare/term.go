@@ -0,0 +1,37 @@
+package bsquare
+
+import (
+	"os"
+	"strings"
+)
+
+// Terminal describes what an output stream can display.
+type Terminal struct {
+	TTY     bool // a terminal rather than a file or pipe
+	Escapes bool // interprets ANSI escape sequences
+	Unicode bool // displays the Unicode board characters
+}
+
+// terminal describes standard output, detected once at startup.
+var terminal = detectTerminal(os.Stdout)
+
+// detectTerminal inspects an output stream, enabling escape processing
+// and UTF-8 output on Windows consoles where it can. Files and pipes,
+// such as CI logs, get no escapes.
+func detectTerminal(f *os.File) Terminal {
+	var t Terminal
+	t.TTY, t.Escapes, t.Unicode = setupConsole(f)
+	return t
+}
+
+// utf8Locale indicates if the locale environment permits UTF-8 output:
+// it selects UTF-8, or is not set at all.
+func utf8Locale() bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			v = strings.ToLower(v)
+			return strings.Contains(v, "utf-8") || strings.Contains(v, "utf8")
+		}
+	}
+	return true
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package bsquare
+
+import "os"
+
+// setupConsole reports if a stream is a terminal, showing escapes unless
+// TERM says otherwise, and if the locale permits Unicode.
+func setupConsole(f *os.File) (tty, escapes, unicode bool) {
+	fi, err := f.Stat()
+	tty = err == nil && fi.Mode()&os.ModeCharDevice != 0
+	return tty, tty && os.Getenv("TERM") != "dumb", utf8Locale()
+}
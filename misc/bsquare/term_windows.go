@@ -0,0 +1,35 @@
+//go:build windows
+
+package bsquare
+
+import (
+	"os"
+	"syscall"
+)
+
+var (
+	kernel32               = syscall.NewLazyDLL("kernel32.dll")
+	procSetConsoleMode     = kernel32.NewProc("SetConsoleMode")
+	procSetConsoleOutputCP = kernel32.NewProc("SetConsoleOutputCP")
+)
+
+const (
+	enableVirtualTerminalProcessing = 0x0004
+	codePageUTF8                    = 65001
+)
+
+// setupConsole reports if a stream is a console, switching it to VT
+// processing and the UTF-8 code page. Consoles too old for either, like
+// cmd.exe before Windows 10, keep the legacy behavior. Redirected output
+// is written as UTF-8 without escapes.
+func setupConsole(f *os.File) (tty, escapes, unicode bool) {
+	h := syscall.Handle(f.Fd())
+	var mode uint32
+	if syscall.GetConsoleMode(h, &mode) != nil {
+		return false, false, true
+	}
+	r, _, _ := procSetConsoleMode.Call(uintptr(h), uintptr(mode|enableVirtualTerminalProcessing))
+	escapes = r != 0 && os.Getenv("TERM") != "dumb"
+	r, _, _ = procSetConsoleOutputCP.Call(codePageUTF8)
+	return true, escapes, r != 0
+}
@@ -0,0 +1,254 @@
+package bsquare
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Theme controls how boards and score maps are drawn.
+type Theme struct {
+	Pieces    [2]string // placed pieces per player
+	Claimed   [2]string // squares only that player may still take
+	Dead      string    // squares neither player may take
+	Empty     string    // squares either player may take
+	Colors    [2]string // SGR parameters per player, e.g. "94"
+	Highlight string    // SGR parameters added to highlighted squares
+	Color     bool      // emit ANSI escapes
+}
+
+// Themes lists the built-in themes by name.
+var Themes = map[string]Theme{
+	"default": {
+		Pieces:    [2]string{"█", "█"},
+		Claimed:   [2]string{"░", "░"},
+		Dead:      " ",
+		Empty:     "∙",
+		Colors:    [2]string{"94", "91"},
+		Highlight: "4",
+		Color:     true,
+	},
+	"ascii": {
+		Pieces:    [2]string{"X", "O"},
+		Claimed:   [2]string{"x", "o"},
+		Dead:      " ",
+		Empty:     ".",
+		Colors:    [2]string{"94", "91"},
+		Highlight: "7",
+		Color:     true,
+	},
+	"contrast": {
+		Pieces:    [2]string{"█", "█"},
+		Claimed:   [2]string{"▒", "▒"},
+		Dead:      " ",
+		Empty:     "·",
+		Colors:    [2]string{"1;96", "1;93"},
+		Highlight: "7",
+		Color:     true,
+	},
+}
+
+// theme is the theme used by the board and score printers.
+var theme = defaultTheme()
+
+// defaultTheme returns the theme suited to the terminal, with color
+// only if it shows escapes and the NO_COLOR environment variable is
+// unset (https://no-color.org/).
+func defaultTheme() Theme {
+	t := Themes[autoTheme()]
+	t.Color = terminal.Escapes && os.Getenv("NO_COLOR") == ""
+	return t
+}
+
+// autoTheme names the default theme, or the ASCII theme when the
+// terminal cannot display Unicode.
+func autoTheme() string {
+	if terminal.Unicode {
+		return "default"
+	}
+	return "ascii"
+}
+
+// paint wraps a string in the escapes for the given SGR parameters.
+func (t *Theme) paint(str string, sgr ...string) string {
+	var params []string
+	for _, p := range sgr {
+		if p != "" {
+			params = append(params, p)
+		}
+	}
+	if !t.Color || len(params) == 0 {
+		return str
+	}
+	return "\x1b[" + strings.Join(params, ";") + "m" + str + "\x1b[0m"
+}
+
+// PrintBoard draws a game state, highlighting square mark (-1 for none).
+func (t *Theme) PrintBoard(w io.Writer, s State, m Mask, mark int) error {
+	buf := bufio.NewWriter(w)
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 5; x++ {
+			i := y*5 + x
+			p0 := s >> i & 1
+			p1 := s >> (i + 25) & 1
+			x0 := m >> (i + 25) & 1
+			x1 := m >> i & 1
+			c, sgr := t.Empty, ""
+			if p0 == 1 {
+				c, sgr = t.Pieces[0], t.Colors[0]
+			} else if p1 == 1 {
+				c, sgr = t.Pieces[1], t.Colors[1]
+			} else if x0 == 1 && x1 == 1 {
+				c = t.Dead
+			} else if x0 == 1 {
+				c, sgr = t.Claimed[0], t.Colors[0]
+			} else if x1 == 1 {
+				c, sgr = t.Claimed[1], t.Colors[1]
+			}
+			if i == mark {
+				buf.WriteString(t.paint(c, sgr, t.Highlight))
+			} else {
+				buf.WriteString(t.paint(c, sgr))
+			}
+		}
+		buf.WriteRune('\n')
+	}
+	buf.WriteRune('\n')
+	return buf.Flush()
+}
+
+// PrintScores draws the score after each legal move in hexadecimal,
+// colored by the winning player, highlighting the best moves.
+func (t *Theme) PrintScores(w io.Writer, tab Minimax, s State, m Mask) error {
+	best := make(map[int]bool)
+	for _, i := range tab.Suggest(s, m) {
+		best[i] = true
+	}
+	buf := bufio.NewWriter(w)
+	for i := 0; i < 5*5; i++ {
+		if m.Valid(i) {
+			score := tab.Evaluate(s.Place(i), m.Place(i))
+			c, sgr := "0", ""
+			if score > 0 {
+				c, sgr = fmt.Sprintf("%x", +score), t.Colors[0]
+			} else if score < 0 {
+				c, sgr = fmt.Sprintf("%x", -score), t.Colors[1]
+			}
+			if best[i] {
+				buf.WriteString(t.paint(c, sgr, t.Highlight))
+			} else {
+				buf.WriteString(t.paint(c, sgr))
+			}
+		} else {
+			buf.WriteRune('-')
+		}
+		if i%5 == 4 {
+			buf.WriteRune('\n')
+		}
+	}
+	buf.WriteRune('\n')
+	return buf.Flush()
+}
+
+// themeFlags holds the display flags shared by commands.
+type themeFlags struct {
+	name      *string
+	color     *string
+	pieces    *string
+	colors    *string
+	highlight *string
+}
+
+// addTheme registers the display flags on a command's flag set.
+func addTheme(flags *flag.FlagSet) themeFlags {
+	names := []string{"auto"}
+	for name := range Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return themeFlags{
+		name:      flags.String("theme", "auto", "board theme ("+strings.Join(names, ", ")+")"),
+		color:     flags.String("color", "auto", "use color (auto, always, never)"),
+		pieces:    flags.String("theme-pieces", "", "piece characters per player, e.g. \"X,O\" (default from -theme)"),
+		colors:    flags.String("theme-colors", "", "SGR colors per player, e.g. \"94,91\" (default from -theme)"),
+		highlight: flags.String("theme-highlight", "", "SGR style of highlighted squares, e.g. \"4\" (default from -theme)"),
+	}
+}
+
+// apply selects the theme chosen by the flags. Automatic choices follow
+// the terminal.
+func (f themeFlags) apply() error {
+	name := *f.name
+	if name == "auto" {
+		name = autoTheme()
+	}
+	t, ok := Themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme: %q", *f.name)
+	}
+	if err := t.customize(*f.pieces, *f.colors, *f.highlight); err != nil {
+		return err
+	}
+	switch *f.color {
+	case "auto":
+		t.Color = t.Color && terminal.Escapes && os.Getenv("NO_COLOR") == ""
+	case "always":
+		t.Color = true
+	case "never":
+		t.Color = false
+	default:
+		return fmt.Errorf("invalid color mode: %q", *f.color)
+	}
+	theme = t
+	return nil
+}
+
+// customize overrides fields of the theme, leaving those given as "".
+// Pieces are a character per player and colors SGR parameters per
+// player, each pair separated by a comma, and the highlight is SGR
+// parameters.
+func (t *Theme) customize(pieces, colors, highlight string) error {
+	if pieces != "" {
+		p, ok := themePair(pieces)
+		if !ok || utf8.RuneCountInString(p[0]) != 1 || utf8.RuneCountInString(p[1]) != 1 {
+			return fmt.Errorf("invalid theme pieces: %q", pieces)
+		}
+		t.Pieces = p
+	}
+	if colors != "" {
+		c, ok := themePair(colors)
+		if !ok || !validSGR(c[0]) || !validSGR(c[1]) {
+			return fmt.Errorf("invalid theme colors: %q", colors)
+		}
+		t.Colors = c
+	}
+	if highlight != "" {
+		if !validSGR(highlight) {
+			return fmt.Errorf("invalid theme highlight: %q", highlight)
+		}
+		t.Highlight = highlight
+	}
+	return nil
+}
+
+// themePair splits a comma-separated value per player.
+func themePair(s string) ([2]string, bool) {
+	a, b, ok := strings.Cut(s, ",")
+	return [2]string{a, b}, ok && !strings.Contains(b, ",")
+}
+
+// validSGR indicates if a string is SGR parameters: numbers separated by
+// semicolons.
+func validSGR(s string) bool {
+	for _, p := range strings.Split(s, ";") {
+		if p == "" || strings.Trim(p, "0123456789") != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,53 @@
+package bsquare
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+// TestThemeSettings checks that the theme fields configured by key and
+// flag parse and are layered over the chosen base theme.
+func TestThemeSettings(t *testing.T) {
+	c, err := ParseSettings(strings.NewReader(`
+theme = "ascii"
+theme-pieces = "#,@"
+theme-highlight = "1;4"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(old Settings, th Theme) { config, theme = old, th }(config, theme)
+	config = c
+
+	flags := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	display := addTheme(flags)
+	if err := parseFlags(flags, []string{"-theme-colors", "32,1;35"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := display.apply(); err != nil {
+		t.Fatal(err)
+	}
+	want := Themes["ascii"]
+	want.Pieces = [2]string{"#", "@"}
+	want.Colors = [2]string{"32", "1;35"}
+	want.Highlight = "1;4"
+	want.Color = theme.Color
+	if theme != want {
+		t.Errorf("got %+v, want %+v", theme, want)
+	}
+
+	for _, bad := range [][3]string{
+		{"X", "", ""},
+		{"XX,O", "", ""},
+		{"X,O,Z", "", ""},
+		{"", "94", ""},
+		{"", "94,red", ""},
+		{"", "", "4;"},
+	} {
+		th := Themes["default"]
+		if err := th.customize(bad[0], bad[1], bad[2]); err == nil {
+			t.Errorf("customize(%q, %q, %q) succeeded", bad[0], bad[1], bad[2])
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package bsquare
+
+import (
+	"context"
+	"flag"
+	"os"
+	"strconv"
+)
+
+// Tree adds the opening tree rooted at a game state down to the given
+// depth to a report, with moves indented by depth. Each node is
+// annotated with its minimax score and the number and percentage of its
+// legal replies leading to a first player win, a draw, or a second
+// player win, counting every reply, including those equivalent by
+// symmetry. Moves equivalent by symmetry are listed only once.
+func (t Minimax) Tree(r *Report, s State, m Mask, depth int) {
+	tab := r.Table("Tree", "move", "depth", "score", "p1", "draw", "p2", "p1 share", "draw share", "p2 share")
+	t.tree(tab, s, m, "root", 0, depth)
+}
+
+func (t Minimax) tree(tab *Table, s State, m Mask, name string, level, depth int) {
+	var p1, draws, p2 int
+	var moves []int
+	seen := make(map[State]bool)
+	if !s.IsComplete(m) {
+		if s.NoMoves(m) {
+			moves = append(moves, -1)
+			p1, draws, p2 = outcome(t.Evaluate(s.Pass(), m.Pass()))
+		}
+		for i := 0; i < 5*5 && !s.NoMoves(m); i++ {
+			if !m.Valid(i) {
+				continue
+			}
+			w, d, l := outcome(t.Evaluate(s.Place(i), m.Place(i)))
+			p1, draws, p2 = p1+w, draws+d, p2+l
+			if c := s.Place(i).Canonicalize(); !seen[c] {
+				seen[c] = true
+				moves = append(moves, i)
+			}
+		}
+	}
+
+	share := func(n int) any {
+		if total := p1 + draws + p2; total > 0 {
+			return percent(100 * float64(n) / float64(total))
+		}
+		return nil
+	}
+	tab.Add(indented{level, name}, level, signed(t.Evaluate(s, m)), p1, draws, p2,
+		share(p1), share(draws), share(p2))
+	if level == depth {
+		return
+	}
+	for _, i := range moves {
+		name := "pass"
+		if i >= 0 {
+			name = strconv.Itoa(i + 1)
+		}
+		cs, cm := child(s, m, i)
+		t.tree(tab, cs, cm, name, level+1, depth)
+	}
+}
+
+// outcome counts a score as a first player win, a draw, or a second
+// player win.
+func outcome(score int) (p1, draw, p2 int) {
+	switch {
+	case score > 0:
+		return 1, 0, 0
+	case score < 0:
+		return 0, 0, 1
+	}
+	return 0, 1, 0
+}
+
+// treeMain implements the "tree" command.
+func treeMain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("tree", flag.ContinueOnError)
+	depth := flags.Int("depth", 1, "tree depth")
+	in := addInput(flags)
+	format := addFormat(flags, "text")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	s, m, err := in.position(flags.Args())
+	if err != nil {
+		return err
+	}
+	t, err := Solved(ctx)
+	if err != nil {
+		return err
+	}
+	r := new(Report)
+	t.Tree(r, s, m, *depth)
+	return format.Write(os.Stdout, r)
+}
@@ -0,0 +1,100 @@
+package main
+
+// C interface for -buildmode=c-shared builds. States are passed as plain
+// 64-bit integers in the bitboard layout, and squares are 0-indexed.
+// Exports reject states that could not arise in a game, and those that
+// need the solved table fail if it could not be loaded, returning -3 as
+// a score or move, all bits set as a move set or state.
+
+import "C"
+
+import (
+	"context"
+	"sync"
+
+	"./bsquare"
+)
+
+const (
+	ffiFailed  = -3
+	ffiNoMoves = ^C.uint(0)
+	ffiNoState = ^C.ulonglong(0)
+)
+
+var (
+	ffiOnce  sync.Once
+	ffiTable bsquare.Minimax
+	ffiErr   error
+)
+
+// ffiSolved returns the shared solved table, solving on first use.
+func ffiSolved() (bsquare.Minimax, error) {
+	ffiOnce.Do(func() { ffiTable, ffiErr = bsquare.Solved(context.Background()) })
+	return ffiTable, ffiErr
+}
+
+//export bsquare_evaluate
+func bsquare_evaluate(state C.ulonglong) C.int {
+	s := bsquare.State(state)
+	t, err := ffiSolved()
+	if err != nil || s.Validate() != nil {
+		return ffiFailed
+	}
+	return C.int(t.Evaluate(s, s.Derive()))
+}
+
+//export bsquare_best_move
+func bsquare_best_move(state C.ulonglong) C.int {
+	s := bsquare.State(state)
+	t, err := ffiSolved()
+	if err != nil || s.Validate() != nil {
+		return ffiFailed
+	}
+	m := s.Derive()
+	if s.IsComplete(m) {
+		return -2
+	}
+	return C.int(bsquare.Perfect{Table: t}.Move(s, m))
+}
+
+//export bsquare_legal_moves
+func bsquare_legal_moves(state C.ulonglong) C.uint {
+	s := bsquare.State(state)
+	if s.Validate() != nil {
+		return ffiNoMoves
+	}
+	m := s.Derive()
+	if s.NoMoves(m) {
+		return 0
+	}
+	return C.uint(m.LegalBits(s.Turn()))
+}
+
+// bsquare_place returns the state unchanged if the move is illegal.
+//
+//export bsquare_place
+func bsquare_place(state C.ulonglong, square C.int) C.ulonglong {
+	if bsquare.State(state).Validate() != nil {
+		return ffiNoState
+	}
+	s, err := bsquare.State(state).PlaceChecked(int(square))
+	if err != nil {
+		return state
+	}
+	return C.ulonglong(s)
+}
+
+// bsquare_pass returns the state unchanged unless the player to move is
+// forced to pass.
+//
+//export bsquare_pass
+func bsquare_pass(state C.ulonglong) C.ulonglong {
+	s := bsquare.State(state)
+	if s.Validate() != nil {
+		return ffiNoState
+	}
+	if m := s.Derive(); !s.NoMoves(m) || s.IsComplete(m) {
+		return state
+	}
+	return C.ulonglong(s.Pass())
+}
@@ -0,0 +1,17 @@
+// Builds without a go.mod otherwise get the runtime behavior of Go 1.20,
+// such as ServeMux ignoring method and wildcard patterns.
+//
+//go:debug default=go1.22
+
+// Command bsquare is the command line interface of package bsquare.
+package main
+
+import (
+	"os"
+
+	"./bsquare"
+)
+
+func main() {
+	os.Exit(bsquare.Main(os.Args[1:]))
+}